@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+// editConfig reads config.yaml, applies edit and writes the file back only when edit succeeds
+func editConfig(configpath string, edit func(config *pkg.Config) error) error {
+	config, err := pkg.ReadConfigFile(configpath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if config.Databases == nil {
+		config.Databases = map[string]pkg.ConnectionInfo{}
+	}
+
+	if err := edit(&config); err != nil {
+		return err
+	}
+	return writeConfig(configpath, config)
+}
+
+type listEntry struct {
+	Alias    string   `json:"alias"`
+	URL      string   `json:"url,omitempty"`
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Database string   `json:"database"`
+	Driver   string   `json:"driver"`
+	User     string   `json:"user"`
+	Tunnel   string   `json:"tunnel,omitempty"`
+	Tag      []string `json:"tag"`
+}
+
+// listConnections prints the databases, optionally filtered by tag, as table or json
+func listConnections(w io.Writer, config pkg.Config, tag string, format string) error {
+	entries := []listEntry{}
+	for _, alias := range config.Aliases(tag) {
+		info := config.Databases[alias]
+		entries = append(entries, listEntry{
+			Alias:    alias,
+			URL:      info.URL,
+			Host:     info.Host,
+			Port:     info.Port,
+			Database: info.Database,
+			Driver:   info.Driver,
+			User:     info.UserAlias,
+			Tunnel:   info.Tunnel,
+			Tag:      info.Tag,
+		})
+	}
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ALIAS\tHOST\tPORT\tDATABASE\tDRIVER\tUSER\tTUNNEL\tTAG")
+		for _, e := range entries {
+			host := e.Host
+			if host == "" {
+				host = e.URL
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\n", e.Alias, host, e.Port, e.Database, e.Driver, e.User, e.Tunnel, strings.Join(e.Tag, ","))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unknown format %q, expected table or json", format)
+}
+
+// setField assigns a single field of a connection, params.<name> and tls.<field> address the nested sections
+func setField(info *pkg.ConnectionInfo, key, value string) (err error) {
+	if param, ok := strings.CutPrefix(key, "params."); ok {
+		if info.Params == nil {
+			info.Params = map[string]string{}
+		}
+		if value == "" {
+			delete(info.Params, param)
+		} else {
+			info.Params[param] = value
+		}
+		return nil
+	}
+
+	if field, ok := strings.CutPrefix(key, "tls."); ok {
+		if info.TLS == nil {
+			info.TLS = &pkg.TLSConfig{}
+		}
+		switch field {
+		case "ca":
+			info.TLS.CA = value
+		case "cert":
+			info.TLS.Cert = value
+		case "key":
+			info.TLS.Key = value
+		case "server_name":
+			info.TLS.ServerName = value
+		case "skip_verify":
+			info.TLS.SkipVerify, err = strconv.ParseBool(value)
+		default:
+			return fmt.Errorf("unknown key %q", key)
+		}
+		if *info.TLS == (pkg.TLSConfig{}) {
+			info.TLS = nil
+		}
+		return err
+	}
+
+	switch key {
+	case "url":
+		info.URL = value
+	case "host":
+		info.Host = value
+	case "port":
+		info.Port, err = strconv.Atoi(value)
+	case "alias", "user":
+		info.UserAlias = value
+	case "database":
+		info.Database = value
+	case "tunnel":
+		info.Tunnel = value
+	case "driver":
+		info.Driver = value
+	case "tag":
+		info.Tag = nil
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				info.Tag = append(info.Tag, tag)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown key %q", key)
+	}
+	return err
+}
+
+// checkConnection rejects the values that would only fail when connecting
+func checkConnection(info pkg.ConnectionInfo) error {
+	if info.URL != "" {
+		if _, _, err := pkg.ParseURL(info.URL); err != nil {
+			return err
+		}
+	} else {
+		if !slices.Contains(pkg.SupportedDrivers, info.Driver) {
+			return fmt.Errorf("unsupported driver %q (supported: %v)", info.Driver, pkg.SupportedDrivers)
+		}
+		if info.Host == "" && info.Driver != pkg.DriverSQLite {
+			return fmt.Errorf("missing host")
+		}
+	}
+
+	if info.Port < 0 || info.Port > 65535 {
+		return fmt.Errorf("port must be between 0 and 65535, got %d", info.Port)
+	}
+	if info.Tunnel != "" {
+		if _, _, err := pkg.ParseTunnel(info.Tunnel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func warnUnknownCredential(config pkg.Config, info pkg.ConnectionInfo) {
+	if _, ok := config.Credentials[info.UserAlias]; info.UserAlias != "" && !ok {
+		slog.Warn("credential not found in config.yaml", "alias", info.UserAlias)
+	}
+}
+
+func addConnection(config *pkg.Config, alias string, info pkg.ConnectionInfo) error {
+	if _, ok := config.Databases[alias]; ok {
+		return fmt.Errorf("alias %s already exists, use set to edit it", alias)
+	}
+	if err := checkConnection(info); err != nil {
+		return err
+	}
+	warnUnknownCredential(*config, info)
+	config.Databases[alias] = info
+	return nil
+}
+
+func setConnection(config *pkg.Config, alias string, assignments []string) error {
+	info, ok := config.Databases[alias]
+	if !ok {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+
+	// nested values are copied, a failed assignment must leave the configuration untouched
+	info.Params = maps.Clone(info.Params)
+	if info.TLS != nil {
+		tlsConfig := *info.TLS
+		info.TLS = &tlsConfig
+	}
+
+	for _, assignment := range assignments {
+		key, value, ok := strings.Cut(assignment, "=")
+		if !ok {
+			return fmt.Errorf("expected key=value, got %q", assignment)
+		}
+		if err := setField(&info, key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := checkConnection(info); err != nil {
+		return err
+	}
+	warnUnknownCredential(*config, info)
+	config.Databases[alias] = info
+	return nil
+}
+
+func removeConnection(config *pkg.Config, alias string) error {
+	if _, ok := config.Databases[alias]; !ok {
+		return fmt.Errorf("alias not found: %s", alias)
+	}
+	delete(config.Databases, alias)
+	return nil
+}
+
+func renameConnection(config *pkg.Config, from, to string) error {
+	info, ok := config.Databases[from]
+	if !ok {
+		return fmt.Errorf("alias not found: %s", from)
+	}
+	if _, ok := config.Databases[to]; ok {
+		return fmt.Errorf("alias %s already exists", to)
+	}
+	delete(config.Databases, from)
+	config.Databases[to] = info
+	return nil
+}
+
+func addCredential(config *pkg.Config, alias string, user pkg.User) error {
+	if _, ok := config.Credentials[alias]; ok {
+		return fmt.Errorf("credential %s already exists", alias)
+	}
+	if user.Username == "" {
+		return fmt.Errorf("missing username")
+	}
+	if config.Credentials == nil {
+		config.Credentials = map[string]pkg.User{}
+	}
+	config.Credentials[alias] = user
+	return nil
+}
+
+// removeCredential refuses to remove a credential still used by a database
+func removeCredential(config *pkg.Config, alias string) error {
+	if _, ok := config.Credentials[alias]; !ok {
+		return fmt.Errorf("credential not found: %s", alias)
+	}
+	if users := credentialUsers(*config, alias); len(users) > 0 {
+		return fmt.Errorf("credential %s is used by %s", alias, strings.Join(users, ", "))
+	}
+
+	delete(config.Credentials, alias)
+	return nil
+}
+
+// credentialUsers returns the sorted aliases of the databases using the credential
+func credentialUsers(config pkg.Config, alias string) []string {
+	users := []string{}
+	for _, name := range config.Aliases("") {
+		if config.Databases[name].UserAlias == alias {
+			users = append(users, name)
+		}
+	}
+	return users
+}
+
+// deleteCredential removes a credential from config.yaml and from credentials.enc, refusing while a
+// database uses it, those of the included files too. Included files are never edited.
+func deleteCredential(configpath, alias string) error {
+	loaded, err := pkg.LoadConfig(configpath)
+	if err != nil {
+		return err
+	}
+	if users := credentialUsers(loaded, alias); len(users) > 0 {
+		return fmt.Errorf("credential %s is used by %s", alias, strings.Join(users, ", "))
+	}
+
+	config, err := pkg.ReadConfigFile(configpath)
+	if err != nil {
+		return err
+	}
+	_, plaintext := config.Credentials[alias]
+
+	encrypted := false
+	if loaded.HasEncryptedCredentials() {
+		encpath := path.Join(path.Dir(configpath), pkg.EncryptedCredentialsFile)
+		credentials, err := pkg.LoadEncryptedCredentials(encpath)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt %s: %w", pkg.EncryptedCredentialsFile, err)
+		}
+
+		if _, encrypted = credentials[alias]; encrypted {
+			remaining := maps.Clone(credentials)
+			delete(remaining, alias)
+
+			passphrase, err := pkg.Passphrase()
+			if err != nil {
+				return err
+			}
+			data, err := pkg.EncryptCredentials(remaining, passphrase)
+			if err != nil {
+				return err
+			}
+			if err := pkg.WriteFileAtomic(encpath, data, 0600); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !plaintext {
+		if !encrypted {
+			return fmt.Errorf("credential not found: %s", alias)
+		}
+		return nil
+	}
+	return editConfig(configpath, func(config *pkg.Config) error {
+		return removeCredential(config, alias)
+	})
+}
+
+// connectionFlags binds the add flags to the fields of info
+func connectionFlags(info *pkg.ConnectionInfo) *flag.FlagSet {
+	flags := flag.NewFlagSet("add", flag.ContinueOnError)
+	flags.StringVar(&info.URL, "url", "", "connection url, e.g. mysql://host:3306/db")
+	flags.StringVar(&info.Host, "host", "", "database host or unix socket path")
+	flags.IntVar(&info.Port, "port", 0, "database port, 0 for unix sockets")
+	flags.StringVar(&info.UserAlias, "user", "", "credential alias")
+	flags.StringVar(&info.Database, "database", "", "database name")
+	flags.StringVar(&info.Tunnel, "tunnel", "", "ssh tunnel, user@host[:port]")
+	flags.StringVar(&info.Driver, "driver", "", fmt.Sprintf("database driver, one of %v", pkg.SupportedDrivers))
+	flags.Func("tag", "tag of the connection, can be repeated", func(tag string) error {
+		info.Tag = append(info.Tag, tag)
+		return nil
+	})
+	return flags
+}
+
+func runList(configpath string, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	tag := flags.String("tag", "", "show only the connections with the tag")
+	format := flags.String("format", "table", "output format, table or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := pkg.LoadConfig(configpath)
+	if err != nil {
+		return err
+	}
+	return listConnections(os.Stdout, config, *tag, *format)
+}
+
+func runAdd(configpath string, args []string) error {
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return fmt.Errorf("usage: connect-manager add <alias> --host <host> --driver <driver> [flags]")
+	}
+	alias := args[0]
+
+	info := pkg.ConnectionInfo{}
+	if err := connectionFlags(&info).Parse(args[1:]); err != nil {
+		return err
+	}
+
+	return editConfig(configpath, func(config *pkg.Config) error {
+		return addConnection(config, alias, info)
+	})
+}
+
+func runCredentials(configpath string, args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: connect-manager credentials add|rm <alias>")
+	}
+	action, alias := args[0], args[1]
+
+	switch action {
+	case "add":
+		user := pkg.User{}
+		ask := false
+		flags := flag.NewFlagSet("credentials add", flag.ContinueOnError)
+		flags.StringVar(&user.Username, "username", "", "database username")
+		flags.StringVar(&user.PasswordCmd, "password-cmd", "", "command printing the password")
+		flags.StringVar(&user.PasswordEnv, "password-env", "", "environment variable holding the password")
+		flags.StringVar(&user.PasswordFile, "password-file", "", "file holding the password")
+		flags.BoolVar(&ask, "ask-password", false, "prompt for a password stored in plaintext")
+		if err := flags.Parse(args[2:]); err != nil {
+			return err
+		}
+
+		if ask {
+			password, err := terminal.ReadPassword("Password: ")
+			if err != nil {
+				return err
+			}
+			user.Password = password
+		}
+
+		err := editConfig(configpath, func(config *pkg.Config) error {
+			return addCredential(config, alias, user)
+		})
+		if err == nil && user.Password != "" {
+			if _, statErr := os.Stat(path.Join(path.Dir(configpath), pkg.EncryptedCredentialsFile)); statErr == nil {
+				slog.Warn("the password is stored in plaintext, run connect-manager encrypt to move it in " + pkg.EncryptedCredentialsFile)
+			}
+		}
+		return err
+
+	case "rm":
+		return deleteCredential(configpath, alias)
+	}
+	return fmt.Errorf("unknown credentials action %q, expected add or rm", action)
+}
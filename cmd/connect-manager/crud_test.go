@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func testConfig() pkg.Config {
+	return pkg.Config{
+		Credentials: map[string]pkg.User{
+			"prod": {Username: "admin"},
+		},
+		Databases: map[string]pkg.ConnectionInfo{
+			"sales":   {Host: "10.0.1.5", Port: 3306, UserAlias: "prod", Database: "sales", Driver: "mysql", Tag: []string{"production"}},
+			"billing": {Host: "10.0.1.6", Port: 3306, UserAlias: "prod", Database: "billing", Driver: "mysql"},
+		},
+	}
+}
+
+func TestSetConnection(t *testing.T) {
+	tests := []struct {
+		assignments []string
+		expected    pkg.ConnectionInfo
+		fails       bool
+	}{
+		{
+			assignments: []string{"port=3307", "tag=production, eu", "params.charset=utf8mb4"},
+			expected: pkg.ConnectionInfo{
+				Host: "10.0.1.5", Port: 3307, UserAlias: "prod", Database: "sales", Driver: "mysql",
+				Tag: []string{"production", "eu"}, Params: map[string]string{"charset": "utf8mb4"},
+			},
+		},
+		{
+			assignments: []string{"tls.ca=/etc/ca.pem", "tls.skip_verify=true"},
+			expected: pkg.ConnectionInfo{
+				Host: "10.0.1.5", Port: 3306, UserAlias: "prod", Database: "sales", Driver: "mysql",
+				Tag: []string{"production"}, TLS: &pkg.TLSConfig{CA: "/etc/ca.pem", SkipVerify: true},
+			},
+		},
+		{assignments: []string{"port=99999"}, fails: true},
+		{assignments: []string{"tunnel=bastion"}, fails: true},
+		{assignments: []string{"driver=oracle"}, fails: true},
+		{assignments: []string{"colour=red"}, fails: true},
+		{assignments: []string{"host"}, fails: true},
+	}
+
+	for _, tt := range tests {
+		config := testConfig()
+		err := setConnection(&config, "sales", tt.assignments)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%v: setConnection() expected error", tt.assignments)
+			}
+			if !reflect.DeepEqual(config.Databases["sales"], testConfig().Databases["sales"]) {
+				t.Errorf("%v: failed setConnection() modified the connection", tt.assignments)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: setConnection() = %v, expected nil", tt.assignments, err)
+		}
+		if got := config.Databases["sales"]; !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%v: setConnection() = %+v, expected %+v", tt.assignments, got, tt.expected)
+		}
+	}
+}
+
+func TestAddRenameRemove(t *testing.T) {
+	config := testConfig()
+
+	if err := addConnection(&config, "sales", pkg.ConnectionInfo{Host: "h", Driver: "mysql"}); err == nil {
+		t.Errorf("addConnection() of an existing alias expected error")
+	}
+	if err := addConnection(&config, "local", pkg.ConnectionInfo{Driver: "sqlite", Database: "/tmp/local.db"}); err != nil {
+		t.Errorf("addConnection() = %v, expected nil", err)
+	}
+
+	if err := renameConnection(&config, "local", "sales"); err == nil {
+		t.Errorf("renameConnection() over an existing alias expected error")
+	}
+	if err := renameConnection(&config, "local", "scratch"); err != nil {
+		t.Errorf("renameConnection() = %v, expected nil", err)
+	}
+	if _, ok := config.Databases["scratch"]; !ok {
+		t.Errorf("renameConnection() did not move the connection: %v", config.Databases)
+	}
+
+	if err := removeConnection(&config, "scratch"); err != nil {
+		t.Errorf("removeConnection() = %v, expected nil", err)
+	}
+	if err := removeConnection(&config, "scratch"); err == nil {
+		t.Errorf("removeConnection() of a missing alias expected error")
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	config := testConfig()
+
+	err := removeCredential(&config, "prod")
+	if err == nil || !strings.Contains(err.Error(), "billing, sales") {
+		t.Errorf("removeCredential() = %v, expected the databases using it", err)
+	}
+
+	if err := addCredential(&config, "ci", pkg.User{Username: "ci", PasswordEnv: "CI_PASSWORD"}); err != nil {
+		t.Errorf("addCredential() = %v, expected nil", err)
+	}
+	if err := addCredential(&config, "ci", pkg.User{Username: "ci"}); err == nil {
+		t.Errorf("addCredential() of an existing alias expected error")
+	}
+	if err := removeCredential(&config, "ci"); err != nil {
+		t.Errorf("removeCredential() = %v, expected nil", err)
+	}
+}
+
+func TestDeleteCredential(t *testing.T) {
+	t.Setenv(pkg.PassphraseEnv, "secret passphrase")
+	dir := t.TempDir()
+	configpath := filepath.Join(dir, "config.yaml")
+	encpath := filepath.Join(dir, pkg.EncryptedCredentialsFile)
+
+	data, err := pkg.EncryptCredentials(map[string]pkg.User{"prod": {Username: "admin"}, "dev": {Username: "dev"}}, "secret passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(encpath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := "include: [team.yaml]\ncredentials:\n  ci:\n    username: ci\n"
+	if err := os.WriteFile(configpath, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	team := "databases:\n  sales:\n    host: 10.0.1.5\n    alias: prod\n    driver: mysql\n"
+	if err := os.WriteFile(filepath.Join(dir, "team.yaml"), []byte(team), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := deleteCredential(configpath, "prod"); err == nil || !strings.Contains(err.Error(), "sales") {
+		t.Errorf("deleteCredential(prod) = %v, expected the included database using it", err)
+	}
+	if err := deleteCredential(configpath, "missing"); err == nil {
+		t.Errorf("deleteCredential(missing) expected error")
+	}
+	for _, alias := range []string{"dev", "ci"} {
+		if err := deleteCredential(configpath, alias); err != nil {
+			t.Errorf("deleteCredential(%s) = %v, expected nil", alias, err)
+		}
+	}
+
+	data, err = os.ReadFile(encpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := pkg.DecryptCredentials(data, "secret passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := credentials["dev"]; ok || len(credentials) != 1 {
+		t.Errorf("deleteCredential() left %v in %s, expected only prod", credentials, pkg.EncryptedCredentialsFile)
+	}
+	got, err := pkg.ReadConfigFile(configpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Credentials) != 0 {
+		t.Errorf("deleteCredential() left %v in config.yaml, expected none", got.Credentials)
+	}
+}
+
+func TestListConnections(t *testing.T) {
+	var buf bytes.Buffer
+	if err := listConnections(&buf, testConfig(), "production", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"alias": "sales"`) || strings.Contains(buf.String(), "billing") {
+		t.Errorf("listConnections() = %s, expected only sales", buf.String())
+	}
+
+	buf.Reset()
+	if err := listConnections(&buf, testConfig(), "", "table"); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "billing") {
+		t.Errorf("listConnections() = %q, expected header and sorted aliases", lines)
+	}
+}
+
+func TestEditConfig(t *testing.T) {
+	configpath := filepath.Join(t.TempDir(), "config.yaml")
+
+	err := editConfig(configpath, func(config *pkg.Config) error {
+		return addConnection(config, "local", pkg.ConnectionInfo{Driver: "sqlite", Database: "local.db"})
+	})
+	if err != nil {
+		t.Fatalf("editConfig() on a missing file = %v, expected nil", err)
+	}
+
+	config, err := pkg.ReadConfigFile(configpath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Databases["local"].Database != "local.db" {
+		t.Errorf("editConfig() wrote %+v", config)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"github.com/google/uuid"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var exportFormats = []string{"csv", "json", "yaml", "dbeaver", "datagrip"}
+
+// exportDocument is the subset of config.yaml handed to other people
+type exportDocument struct {
+	Credentials map[string]pkg.User           `yaml:"credentials,omitempty"`
+	Databases   map[string]pkg.ConnectionInfo `yaml:"databases"`
+}
+
+// exportedConnection is a resolved database entry with its credential
+type exportedConnection struct {
+	Alias string
+	Info  pkg.ConnectionInfo
+	User  pkg.User
+}
+
+// stripSecrets keeps only the username of a credential
+func stripSecrets(user pkg.User) pkg.User {
+	return pkg.User{Username: user.Username}
+}
+
+// exportCredential looks up the credential alias. Without secrets credentials.enc is not decrypted,
+// asking the passphrase only for usernames: its credentials are exported without one.
+func exportCredential(config pkg.Config, alias string, secrets bool) (pkg.User, error) {
+	if secrets {
+		return config.Credential(alias)
+	}
+	if user, ok := config.Credentials[alias]; ok {
+		return stripSecrets(user), nil
+	}
+	if config.HasEncryptedCredentials() {
+		return pkg.User{}, nil
+	}
+	return config.Credential(alias)
+}
+
+// stripURLPassword removes the password written in a connection url, keeping the username
+func stripURLPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if _, ok := u.User.Password(); !ok {
+		return raw
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// collectConnections resolves the databases with the tag, loading their credentials
+func collectConnections(config pkg.Config, tag string, secrets bool) ([]exportedConnection, error) {
+	connections := []exportedConnection{}
+	for _, alias := range config.Aliases(tag) {
+		info, user, err := config.Databases[alias].Resolve()
+		if err == nil && info.UserAlias != "" {
+			user, err = exportCredential(config, info.UserAlias, secrets)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", alias, err)
+		}
+		if !secrets {
+			user = stripSecrets(user)
+		}
+		connections = append(connections, exportedConnection{Alias: alias, Info: info, User: user})
+	}
+	return connections, nil
+}
+
+func exportDocumentOf(config pkg.Config, tag string, secrets bool) (exportDocument, error) {
+	doc := exportDocument{Databases: map[string]pkg.ConnectionInfo{}}
+	for _, alias := range config.Aliases(tag) {
+		info := config.Databases[alias]
+		if !secrets {
+			info.URL = stripURLPassword(info.URL)
+		}
+		doc.Databases[alias] = info
+		if info.UserAlias == "" {
+			continue
+		}
+
+		user, err := exportCredential(config, info.UserAlias, secrets)
+		if err != nil {
+			return doc, fmt.Errorf("%s: %w", alias, err)
+		}
+		if doc.Credentials == nil {
+			doc.Credentials = map[string]pkg.User{}
+		}
+		doc.Credentials[info.UserAlias] = user
+	}
+	return doc, nil
+}
+
+func exportConnections(w io.Writer, config pkg.Config, format, tag string, secrets bool) error {
+	switch format {
+	case "yaml", "json":
+		doc, err := exportDocumentOf(config, tag, secrets)
+		if err != nil {
+			return err
+		}
+		if format == "yaml" {
+			encoder := yamlv3.NewEncoder(w)
+			encoder.SetIndent(2)
+			return encoder.Encode(doc)
+		}
+		return writeJSON(w, doc)
+
+	case "csv", "dbeaver", "datagrip":
+		connections, err := collectConnections(config, tag, secrets)
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "csv":
+			return exportCsv(w, connections)
+		case "dbeaver":
+			return exportDBeaver(w, connections)
+		}
+		return exportDataGrip(w, connections)
+	}
+	return fmt.Errorf("unknown format %q, expected one of %v", format, exportFormats)
+}
+
+// writeJSON encodes v with the yaml key names, going through a generic yaml document
+func writeJSON(w io.Writer, v any) error {
+	content, err := yamlv3.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var generic any
+	if err := yamlv3.Unmarshal(content, &generic); err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(generic)
+}
+
+// exportCsv writes the columns understood by import
+func exportCsv(w io.Writer, connections []exportedConnection) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"alias", "host", "port", "database", "tunnel", "user", "driver", "tag"})
+	for _, c := range connections {
+		writer.Write([]string{
+			c.Alias,
+			c.Info.Host,
+			strconv.Itoa(c.Info.Port),
+			c.Info.Database,
+			c.Info.Tunnel,
+			c.Info.UserAlias,
+			c.Info.Driver,
+			strings.Join(c.Info.Tag, ","),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func jdbcURL(info pkg.ConnectionInfo) string {
+	if info.Driver == pkg.DriverSQLite {
+		return "jdbc:sqlite:" + info.Database
+	}
+
+	scheme := "mysql"
+	if info.Driver == pkg.DriverPostgres {
+		scheme = "postgresql"
+	}
+	// without a port the driver default is used
+	host := info.Host
+	if info.Port != 0 {
+		host = fmt.Sprintf("%s:%d", info.Host, info.Port)
+	}
+	return fmt.Sprintf("jdbc:%s://%s/%s", scheme, host, info.Database)
+}
+
+type dbeaverDriver struct {
+	Provider string
+	Driver   string
+}
+
+var dbeaverDrivers = map[string]dbeaverDriver{
+	pkg.DriverMySQL:    {Provider: "mysql", Driver: "mysql8"},
+	pkg.DriverPostgres: {Provider: "postgresql", Driver: "postgres-jdbc"},
+	pkg.DriverSQLite:   {Provider: "generic", Driver: "sqlite_jdbc"},
+}
+
+// exportDBeaver writes a data-sources.json, tags become folders and tunnels use the ssh agent
+func exportDBeaver(w io.Writer, connections []exportedConnection) error {
+	folders := map[string]any{}
+	sources := map[string]any{}
+
+	for _, c := range connections {
+		driver := dbeaverDrivers[c.Info.Driver]
+		configuration := map[string]any{
+			"host":              c.Info.Host,
+			"database":          c.Info.Database,
+			"url":               jdbcURL(c.Info),
+			"configurationType": "MANUAL",
+			"auth-model":        "native",
+			"user":              c.User.Username,
+		}
+		if c.Info.Port != 0 {
+			configuration["port"] = strconv.Itoa(c.Info.Port)
+		}
+		if c.User.Password != "" {
+			configuration["password"] = c.User.Password
+		}
+
+		if c.Info.Tunnel != "" {
+			sshUser, sshAddr, err := pkg.ParseTunnel(c.Info.Tunnel)
+			if err != nil {
+				return fmt.Errorf("%s: %w", c.Alias, err)
+			}
+			host, port, _ := strings.Cut(sshAddr, ":")
+			configuration["handlers"] = map[string]any{
+				"ssh_tunnel": map[string]any{
+					"type":    "TUNNEL",
+					"enabled": true,
+					"user":    sshUser,
+					"properties": map[string]any{
+						"host":     host,
+						"port":     port,
+						"authType": "AGENT",
+					},
+				},
+			}
+		}
+
+		source := map[string]any{
+			"provider":      driver.Provider,
+			"driver":        driver.Driver,
+			"name":          c.Alias,
+			"save-password": c.User.Password != "",
+			"configuration": configuration,
+		}
+		if len(c.Info.Tag) > 0 {
+			source["folder"] = c.Info.Tag[0]
+			folders[c.Info.Tag[0]] = map[string]any{}
+		}
+		sources[c.Alias] = source
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(map[string]any{
+		"folders":     folders,
+		"connections": sources,
+	})
+}
+
+type dataGripProject struct {
+	XMLName   xml.Name `xml:"project"`
+	Version   string   `xml:"version,attr"`
+	Component struct {
+		Name        string               `xml:"name,attr"`
+		Format      string               `xml:"format,attr"`
+		DataSources []dataGripDataSource `xml:"data-source"`
+	} `xml:"component"`
+}
+
+type dataGripDataSource struct {
+	Source      string `xml:"source,attr"`
+	Name        string `xml:"name,attr"`
+	UUID        string `xml:"uuid,attr"`
+	Group       string `xml:"group,attr,omitempty"`
+	DriverRef   string `xml:"driver-ref"`
+	Synchronize bool   `xml:"synchronize"`
+	JdbcDriver  string `xml:"jdbc-driver"`
+	JdbcURL     string `xml:"jdbc-url"`
+	UserName    string `xml:"user-name,omitempty"`
+	Comment     string `xml:"comment,omitempty"`
+}
+
+var dataGripDrivers = map[string][2]string{
+	pkg.DriverMySQL:    {"mysql.8", "com.mysql.cj.jdbc.Driver"},
+	pkg.DriverPostgres: {"postgresql", "org.postgresql.Driver"},
+	pkg.DriverSQLite:   {"sqlite.xerial", "org.sqlite.JDBC"},
+}
+
+// exportDataGrip writes a .idea/dataSources.xml, passwords are never exported since DataGrip keeps them in the OS keychain
+func exportDataGrip(w io.Writer, connections []exportedConnection) error {
+	project := dataGripProject{Version: "4"}
+	project.Component.Name = "DataSourceManagerImpl"
+	project.Component.Format = "xml"
+
+	for _, c := range connections {
+		driver := dataGripDrivers[c.Info.Driver]
+		source := dataGripDataSource{
+			Source:      "LOCAL",
+			Name:        c.Alias,
+			UUID:        uuid.NewSHA1(uuid.NameSpaceURL, []byte("connect:"+c.Alias)).String(),
+			DriverRef:   driver[0],
+			Synchronize: true,
+			JdbcDriver:  driver[1],
+			JdbcURL:     jdbcURL(c.Info),
+			UserName:    c.User.Username,
+		}
+		if len(c.Info.Tag) > 0 {
+			source.Group = c.Info.Tag[0]
+		}
+		// ssh tunnels live in a separate DataGrip file, leave a note to configure them by hand
+		if c.Info.Tunnel != "" {
+			source.Comment = "ssh tunnel: " + c.Info.Tunnel
+		}
+		project.Component.DataSources = append(project.Component.DataSources, source)
+	}
+
+	io.WriteString(w, xml.Header)
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(project); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func runExport(configpath string, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", "yaml", fmt.Sprintf("output format, one of %v", exportFormats))
+	tag := flags.String("tag", "", "export only the connections with the tag")
+	noSecrets := flags.Bool("no-secrets", false, "omit passwords and secret sources, keeping only usernames")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	config, err := pkg.LoadConfig(configpath)
+	if err != nil {
+		return err
+	}
+	return exportConnections(os.Stdout, config, *format, *tag, !*noSecrets)
+}
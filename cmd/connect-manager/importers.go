@@ -0,0 +1,630 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+var importFormats = []string{"csv", "dbeaver", "datagrip", "pgpass", "mycnf"}
+
+// importedConnection is a connection read from another tool, with the credential it uses
+type importedConnection struct {
+	Alias string
+	Info  pkg.ConnectionInfo
+	User  pkg.User
+}
+
+// aliasName turns a connection name of a GUI tool into an alias usable on the command line
+func aliasName(name string) string {
+	return strings.Join(strings.Fields(name), "-")
+}
+
+// credentialAlias returns the alias of an identical credential, or the free alias a new credential
+// would be added under, reporting whether the credential is new
+func credentialAlias(config *pkg.Config, user pkg.User) (string, bool) {
+	if user.Username == "" {
+		return "", false
+	}
+
+	aliases := make([]string, 0, len(config.Credentials))
+	for alias := range config.Credentials {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if reflect.DeepEqual(config.Credentials[alias], user) {
+			return alias, false
+		}
+	}
+
+	alias := aliasName(user.Username)
+	for i := 2; ; i++ {
+		if _, ok := config.Credentials[alias]; !ok {
+			break
+		}
+		alias = fmt.Sprintf("%s-%d", aliasName(user.Username), i)
+	}
+	return alias, true
+}
+
+const (
+	ConflictFail      = "fail"
+	ConflictSkip      = "skip"
+	ConflictOverwrite = "overwrite"
+)
+
+// importChange describes what an import does to an alias, Fields lists the modified values
+type importChange struct {
+	Alias  string
+	Action string
+	Fields []string
+}
+
+func (c importChange) String() string {
+	symbols := map[string]string{"add": "+", "update": "~", "unchanged": "=", "skip": "!", "credential": "+"}
+	out := fmt.Sprintf("%s %s", symbols[c.Action], c.Alias)
+	switch c.Action {
+	case "skip":
+		out += " (skipped, already exists)"
+	case "credential":
+		out = fmt.Sprintf("+ credential %s", c.Alias)
+	}
+	for _, field := range c.Fields {
+		out += "\n    " + field
+	}
+	return out
+}
+
+// yamlValues flattens a value to its yaml keys, e.g. params.charset
+func yamlValues(v any) map[string]string {
+	content, _ := yamlv3.Marshal(v)
+	var generic map[string]any
+	yamlv3.Unmarshal(content, &generic)
+
+	values := map[string]string{}
+	var flatten func(prefix string, value any)
+	flatten = func(prefix string, value any) {
+		switch value := value.(type) {
+		case map[string]any:
+			for key, nested := range value {
+				flatten(prefix+key+".", nested)
+			}
+		default:
+			if s := fmt.Sprint(value); s != "" && s != "[]" && s != "0" && s != "<nil>" {
+				values[strings.TrimSuffix(prefix, ".")] = s
+			}
+		}
+	}
+	flatten("", generic)
+	return values
+}
+
+// diffConnection lists the fields that differ, as "key: old -> new"
+func diffConnection(old, updated pkg.ConnectionInfo) []string {
+	before, after := yamlValues(old), yamlValues(updated)
+	keys := []string{}
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	fields := []string{}
+	for _, key := range keys {
+		if before[key] == after[key] {
+			continue
+		}
+		if key == "url" {
+			fields = append(fields, fmt.Sprintf("%s: %q -> %q", key, redactURLPassword(before[key]), redactURLPassword(after[key])))
+		} else {
+			fields = append(fields, fmt.Sprintf("%s: %q -> %q", key, before[key], after[key]))
+		}
+	}
+	return fields
+}
+
+// redactURLPassword hides the password of a connection url, so that the diff can be printed
+func redactURLPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// mergeConnections adds the imported connections to the configuration. An alias already present with
+// different values is replaced, skipped or makes the whole import fail according to onConflict.
+func mergeConnections(config *pkg.Config, connections []importedConnection, onConflict string) ([]importChange, error) {
+	if !slices.Contains([]string{ConflictFail, ConflictSkip, ConflictOverwrite}, onConflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q, expected fail, skip or overwrite", onConflict)
+	}
+
+	changes := []importChange{}
+	conflicts := []string{}
+	for _, c := range connections {
+		credential, newCredential := credentialAlias(config, c.User)
+		if credential != "" {
+			c.Info.UserAlias = credential
+		}
+		// the credential is added only together with the connection using it
+		addCredential := func() {
+			if !newCredential {
+				return
+			}
+			if config.Credentials == nil {
+				config.Credentials = map[string]pkg.User{}
+			}
+			config.Credentials[credential] = c.User
+			changes = append(changes, importChange{Alias: credential, Action: "credential"})
+		}
+
+		existing, ok := config.Databases[c.Alias]
+		if !ok {
+			addCredential()
+			config.Databases[c.Alias] = c.Info
+			changes = append(changes, importChange{Alias: c.Alias, Action: "add"})
+			continue
+		}
+
+		fields := diffConnection(existing, c.Info)
+		switch {
+		case len(fields) == 0:
+			changes = append(changes, importChange{Alias: c.Alias, Action: "unchanged"})
+		case onConflict == ConflictSkip:
+			changes = append(changes, importChange{Alias: c.Alias, Action: "skip", Fields: fields})
+		case onConflict == ConflictFail:
+			conflicts = append(conflicts, c.Alias)
+		default:
+			addCredential()
+			config.Databases[c.Alias] = c.Info
+			changes = append(changes, importChange{Alias: c.Alias, Action: "update", Fields: fields})
+		}
+	}
+
+	if len(conflicts) > 0 {
+		return changes, fmt.Errorf("aliases already defined with different values: %s (use --on-conflict skip|overwrite)", strings.Join(conflicts, ", "))
+	}
+	return changes, nil
+}
+
+func parseImport(format string, r io.Reader) ([]importedConnection, error) {
+	switch format {
+	case "csv":
+		return readCsv(r)
+	case "dbeaver":
+		return readDBeaver(r)
+	case "datagrip":
+		return readDataGrip(r)
+	case "pgpass":
+		return readPgpass(r)
+	case "mycnf":
+		return readMyCnf(r)
+	}
+	return nil, fmt.Errorf("unknown format %q, expected one of %v", format, importFormats)
+}
+
+// driverOf maps the driver or provider names of the GUI tools to the drivers of connect
+func driverOf(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.Contains(name, "mysql"), strings.Contains(name, "mariadb"):
+		return pkg.DriverMySQL
+	case strings.Contains(name, "postgres"):
+		return pkg.DriverPostgres
+	case strings.Contains(name, "sqlite"):
+		return pkg.DriverSQLite
+	}
+	return ""
+}
+
+type dbeaverSource struct {
+	Name          string `json:"name"`
+	Provider      string `json:"provider"`
+	Driver        string `json:"driver"`
+	Folder        string `json:"folder"`
+	Configuration struct {
+		Host     string `json:"host"`
+		Port     string `json:"port"`
+		Database string `json:"database"`
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Handlers map[string]struct {
+			Enabled    bool           `json:"enabled"`
+			User       string         `json:"user"`
+			Properties map[string]any `json:"properties"`
+		} `json:"handlers"`
+	} `json:"configuration"`
+}
+
+// readDBeaver reads a data-sources.json, the folder of a connection becomes its tag
+func readDBeaver(r io.Reader) ([]importedConnection, error) {
+	var doc struct {
+		Connections map[string]dbeaverSource `json:"connections"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(doc.Connections))
+	for id := range doc.Connections {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	connections := []importedConnection{}
+	for _, id := range ids {
+		source := doc.Connections[id]
+		cfg := source.Configuration
+
+		name := source.Name
+		if name == "" {
+			name = id
+		}
+		driver := driverOf(source.Provider + " " + source.Driver)
+		if driver == "" {
+			slog.Warn("skipping connection with unsupported driver", "name", name, "provider", source.Provider)
+			continue
+		}
+
+		info := pkg.ConnectionInfo{
+			Host:     cfg.Host,
+			Database: cfg.Database,
+			Driver:   driver,
+		}
+		if cfg.Port != "" {
+			port, err := strconv.Atoi(cfg.Port)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid port %q", name, cfg.Port)
+			}
+			info.Port = port
+		}
+		if source.Folder != "" {
+			info.Tag = []string{source.Folder}
+		}
+
+		if ssh, ok := cfg.Handlers["ssh_tunnel"]; ok && ssh.Enabled {
+			user := ssh.User
+			if user == "" {
+				user, _ = ssh.Properties["user"].(string)
+			}
+			host, _ := ssh.Properties["host"].(string)
+			if user == "" || host == "" {
+				slog.Warn("skipping ssh tunnel without user or host", "name", name)
+			} else {
+				info.Tunnel = user + "@" + host
+				if port := fmt.Sprint(ssh.Properties["port"]); port != "" && port != "<nil>" && port != "22" {
+					info.Tunnel += ":" + port
+				}
+			}
+		}
+
+		connections = append(connections, importedConnection{
+			Alias: aliasName(name),
+			Info:  info,
+			User:  pkg.User{Username: cfg.User, Password: cfg.Password},
+		})
+	}
+	return connections, nil
+}
+
+// parseJdbcURL reads jdbc:mysql://, jdbc:mariadb://, jdbc:postgresql:// and jdbc:sqlite: urls, dropping the jdbc parameters
+func parseJdbcURL(jdbc string) (pkg.ConnectionInfo, error) {
+	raw, ok := strings.CutPrefix(jdbc, "jdbc:")
+	if !ok {
+		return pkg.ConnectionInfo{}, fmt.Errorf("not a jdbc url: %s", jdbc)
+	}
+
+	if path, ok := strings.CutPrefix(raw, "sqlite:"); ok {
+		return pkg.ConnectionInfo{Driver: pkg.DriverSQLite, Database: path}, nil
+	}
+	if rest, ok := strings.CutPrefix(raw, "mariadb:"); ok {
+		raw = "mysql:" + rest
+	}
+	raw, _, _ = strings.Cut(raw, "?")
+
+	info, _, err := pkg.ParseURL(raw)
+	return info, err
+}
+
+// readDataGrip reads a .idea/dataSources.xml (or dataSources.local.xml), groups become tags
+func readDataGrip(r io.Reader) ([]importedConnection, error) {
+	var project struct {
+		Components []struct {
+			DataSources []struct {
+				Name     string `xml:"name,attr"`
+				Group    string `xml:"group,attr"`
+				JdbcURL  string `xml:"jdbc-url"`
+				UserName string `xml:"user-name"`
+			} `xml:"data-source"`
+		} `xml:"component"`
+	}
+	if err := xml.NewDecoder(r).Decode(&project); err != nil {
+		return nil, err
+	}
+
+	connections := []importedConnection{}
+	for _, component := range project.Components {
+		for _, source := range component.DataSources {
+			info, err := parseJdbcURL(source.JdbcURL)
+			if err != nil {
+				slog.Warn("skipping data source", "name", source.Name, "err", err)
+				continue
+			}
+			if source.Group != "" {
+				info.Tag = []string{source.Group}
+			}
+			connections = append(connections, importedConnection{
+				Alias: aliasName(source.Name),
+				Info:  info,
+				User:  pkg.User{Username: source.UserName},
+			})
+		}
+	}
+	return connections, nil
+}
+
+// splitPgpass splits a .pgpass line on the colons not escaped by a backslash
+func splitPgpass(line string) []string {
+	fields := []string{}
+	var field strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case line[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(line[i])
+		}
+	}
+	return append(fields, field.String())
+}
+
+// readPgpass reads hostname:port:database:username:password lines, wildcard hosts cannot become connections
+func readPgpass(r io.Reader) ([]importedConnection, error) {
+	connections := []importedConnection{}
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitPgpass(line)
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("line %d: expected hostname:port:database:username:password", lineno)
+		}
+		host, port, database, username, password := fields[0], fields[1], fields[2], fields[3], fields[4]
+		if host == "*" {
+			slog.Warn("skipping wildcard host", "line", lineno)
+			continue
+		}
+
+		info := pkg.ConnectionInfo{Host: host, Port: 5432, Driver: pkg.DriverPostgres}
+		if port != "*" {
+			var err error
+			if info.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("line %d: invalid port %q", lineno, port)
+			}
+		}
+
+		alias := host
+		if database != "*" {
+			info.Database = database
+			alias = database + "@" + host
+		}
+
+		user := pkg.User{Username: username, Password: password}
+		if username == "*" {
+			user = pkg.User{}
+		}
+		connections = append(connections, importedConnection{Alias: alias, Info: info, User: user})
+	}
+	return connections, scanner.Err()
+}
+
+// option groups of the other mysql programs, e.g. [mysqld] or [mysqldump]
+var mysqlPrograms = map[string]bool{
+	"d": true, "d_safe": true, "dump": true, "admin": true, "import": true, "check": true,
+	"show": true, "slap": true, "binlog": true, "pump": true, "_upgrade": true, "_secure_installation": true,
+}
+
+// readMyCnf reads the client sections of a my.cnf. [client] and [mysql] hold the defaults, every
+// [client<suffix>] or [mysql<suffix>] group becomes the connection <suffix> (see --defaults-group-suffix)
+func readMyCnf(r io.Reader) ([]importedConnection, error) {
+	sections := map[string]map[string]string{}
+	order := []string{}
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "!"):
+			slog.Warn("include directives are not followed", "line", lineno)
+			continue
+		case strings.HasPrefix(line, "["):
+			name, ok := strings.CutSuffix(line, "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated section", lineno)
+			}
+			section = strings.ToLower(strings.TrimSpace(name[1:]))
+			if _, ok := sections[section]; !ok {
+				sections[section] = map[string]string{}
+				order = append(order, section)
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, fmt.Errorf("line %d: option outside of a section", lineno)
+		}
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		sections[section][key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	defaults := map[string]string{}
+	for _, name := range []string{"client", "mysql"} {
+		for key, value := range sections[name] {
+			defaults[key] = value
+		}
+	}
+
+	groups := map[string]map[string]string{}
+	aliases := []string{}
+	for _, name := range order {
+		suffix, ok := strings.CutPrefix(name, "client")
+		if !ok {
+			suffix, ok = strings.CutPrefix(name, "mysql")
+			ok = ok && !mysqlPrograms[suffix]
+		}
+		if !ok || suffix == "" {
+			continue
+		}
+		alias := strings.TrimLeft(suffix, "-_")
+		if _, ok := groups[alias]; !ok {
+			groups[alias] = map[string]string{}
+			aliases = append(aliases, alias)
+		}
+		for key, value := range sections[name] {
+			groups[alias][key] = value
+		}
+	}
+
+	if len(aliases) == 0 && len(defaults) > 0 {
+		alias := defaults["host"]
+		if alias == "" {
+			alias = "localhost"
+		}
+		groups[alias] = map[string]string{}
+		aliases = append(aliases, alias)
+	}
+
+	connections := []importedConnection{}
+	for _, alias := range aliases {
+		options := map[string]string{}
+		for key, value := range defaults {
+			options[key] = value
+		}
+		for key, value := range groups[alias] {
+			options[key] = value
+		}
+
+		info := pkg.ConnectionInfo{Host: options["host"], Port: 3306, Database: options["database"], Driver: pkg.DriverMySQL}
+		if info.Host == "" {
+			info.Host = "localhost"
+		}
+		if port, ok := options["port"]; ok {
+			var err error
+			if info.Port, err = strconv.Atoi(port); err != nil {
+				return nil, fmt.Errorf("[%s]: invalid port %q", alias, port)
+			}
+		}
+		if socket, ok := options["socket"]; ok && info.Host == "localhost" {
+			info.Host, info.Port = socket, 0
+		}
+
+		connections = append(connections, importedConnection{
+			Alias: alias,
+			Info:  info,
+			User:  pkg.User{Username: options["user"], Password: options["password"]},
+		})
+	}
+	return connections, nil
+}
+
+// default locations of the files read by import --from
+var importDefaults = map[string]string{
+	"pgpass": "~/.pgpass",
+	"mycnf":  "~/.my.cnf",
+}
+
+func runImport(configpath string, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("from", "csv", fmt.Sprintf("format of the file, one of %v", importFormats))
+	dryRun := flags.Bool("dry-run", false, "print the changes without writing config.yaml")
+	onConflict := flags.String("on-conflict", ConflictFail, "what to do with existing aliases: fail, skip or overwrite")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	filename := flags.Arg(0)
+	if filename == "" {
+		filename = importDefaults[*format]
+	}
+	if filename == "" {
+		return fmt.Errorf("usage: connect-manager import [--from format] [--dry-run] [--on-conflict fail|skip|overwrite] <filename>")
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(filename, "~/") {
+		filename = filepath.Join(home, filename[2:])
+	}
+	slog.Info("Importing file", "filename", filename, "format", *format)
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	connections, err := parseImport(*format, f)
+	if err != nil {
+		return err
+	}
+
+	apply := func(config *pkg.Config) error {
+		changes, err := mergeConnections(config, connections, *onConflict)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		return nil
+	}
+
+	if *dryRun {
+		config, err := pkg.ReadConfigFile(configpath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if config.Databases == nil {
+			config.Databases = map[string]pkg.ConnectionInfo{}
+		}
+		return apply(&config)
+	}
+	return editConfig(configpath, apply)
+}
@@ -2,106 +2,340 @@ package main
 
 import (
 	"codeberg.org/ale-cci/connect/pkg"
+	"codeberg.org/ale-cci/connect/pkg/terminal"
 	"encoding/csv"
 	"errors"
-	"gopkg.in/yaml.v2"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
-	"strconv"
+	"path"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
 )
 
 func help() {
-	slog.Error("usage: connect-manager import <filename>")
+	slog.Error(`usage: connect-manager <command>
+
+  list [--tag <tag>] [--format table|json]
+  add <alias> --host <host> --driver <driver> [--port n] [--user alias] [--database db] [--tunnel user@host] [--tag t] [--url url]
+  set <alias> key=value...
+  rm <alias>
+  rename <old> <new>
+  credentials add <alias> --username <name> [--password-cmd cmd | --password-env var | --password-file path | --ask-password]
+  credentials rm <alias>
+  import [--from csv|dbeaver|datagrip|pgpass|mycnf] [--dry-run] [--on-conflict fail|skip|overwrite] <filename>
+  export [--format csv|json|yaml|dbeaver|datagrip] [--tag <tag>] [--no-secrets]
+  encrypt | decrypt
+  validate [filename]`)
 }
 
 func main() {
-	if len(os.Args) < 3 {
+	if len(os.Args) < 2 {
 		help()
 		os.Exit(1)
 	}
 
+	configpath := pkg.DefaultConfigPath()
+
 	switch os.Args[1] {
 	case "import":
-		filename := os.Args[2]
-		slog.Info("Importing file", "filename", filename)
+		if err := runImport(configpath, os.Args[2:]); err != nil {
+			slog.Error("failed to import", "err", err)
+			os.Exit(1)
+		}
+
+	case "encrypt":
+		if err := encryptCredentials(configpath); err != nil {
+			slog.Error("failed to encrypt credentials", "err", err)
+			os.Exit(1)
+		}
+
+	case "decrypt":
+		if err := decryptCredentials(configpath); err != nil {
+			slog.Error("failed to decrypt credentials", "err", err)
+			os.Exit(1)
+		}
+
+	case "list", "add", "set", "rm", "rename", "credentials", "export":
+		if err := runCommand(configpath, os.Args[1], os.Args[2:]); err != nil {
+			slog.Error("failed to "+os.Args[1], "err", err)
+			os.Exit(1)
+		}
 
-		configpath := pkg.ConfigPath("config.yaml")
+	case "validate":
+		filename := configpath
+		if len(os.Args) > 2 {
+			filename = os.Args[2]
+		}
 
-		config, err := pkg.LoadConfig(configpath)
+		problems, err := pkg.ValidateConfigFile(filename)
 		if err != nil {
-			config = pkg.Config{
-				Databases: map[string]pkg.ConnectionInfo{},
-			}
+			slog.Error("failed to read config", "err", err)
+			os.Exit(1)
+		}
+		for _, problem := range problems {
+			fmt.Println(problem)
+		}
+		if len(problems) > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		help()
+		os.Exit(1)
+	}
+}
+
+func runCommand(configpath string, command string, args []string) error {
+	switch command {
+	case "list":
+		return runList(configpath, args)
+	case "add":
+		return runAdd(configpath, args)
+	case "export":
+		return runExport(configpath, args)
+	case "credentials":
+		return runCredentials(configpath, args)
+	case "set":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: connect-manager set <alias> key=value...")
+		}
+		return editConfig(configpath, func(config *pkg.Config) error {
+			return setConnection(config, args[0], args[1:])
+		})
+	case "rm":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: connect-manager rm <alias>")
+		}
+		return editConfig(configpath, func(config *pkg.Config) error {
+			return removeConnection(config, args[0])
+		})
+	case "rename":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: connect-manager rename <old> <new>")
+		}
+		return editConfig(configpath, func(config *pkg.Config) error {
+			return renameConnection(config, args[0], args[1])
+		})
+	}
+	return fmt.Errorf("unknown command %s", command)
+}
+
+func writeConfig(configpath string, config pkg.Config) error {
+	return pkg.WriteConfigFile(configpath, config)
+}
+
+// encryptCredentials moves the credentials section of config.yaml into credentials.enc,
+// merging them with the credentials already encrypted there
+func encryptCredentials(configpath string) error {
+	config, err := pkg.ReadConfigFile(configpath)
+	if err != nil {
+		return err
+	}
+
+	if len(config.Credentials) == 0 {
+		return fmt.Errorf("no plaintext credentials to encrypt in %s", configpath)
+	}
+
+	encpath := path.Join(path.Dir(configpath), pkg.EncryptedCredentialsFile)
+	credentials := map[string]pkg.User{}
+
+	var passphrase string
+	if _, err := os.Stat(encpath); err == nil {
+		existing, err := pkg.LoadEncryptedCredentials(encpath)
+		if err != nil {
+			return err
+		}
+		for alias, user := range existing {
+			credentials[alias] = user
 		}
 
-		err = readCsv(config, filename)
+		passphrase, err = pkg.Passphrase()
+		if err != nil {
+			return err
+		}
+	} else {
+		passphrase, err = newPassphrase()
 		if err != nil {
-			slog.Error("failed to read csv file", "err", err)
-		} else {
+			return err
+		}
+	}
 
-			file, err := os.Create(configpath)
-			if err != nil {
-				slog.Error("Failed to open file", "err", err)
-				os.Exit(1)
-			}
+	for alias, user := range config.Credentials {
+		credentials[alias] = user
+	}
 
-			if err = yaml.NewEncoder(file).Encode(config); err != nil {
-				slog.Error("failed to writing config", "err", err)
-				os.Exit(1)
-			}
+	data, err := pkg.EncryptCredentials(credentials, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := pkg.WriteFileAtomic(encpath, data, 0600); err != nil {
+		return err
+	}
+
+	config.Credentials = nil
+	if err := writeConfig(configpath, config); err != nil {
+		return err
+	}
+	slog.Info("Credentials encrypted", "file", encpath, "count", len(credentials))
+	return nil
+}
+
+// decryptCredentials moves the content of credentials.enc back into config.yaml
+func decryptCredentials(configpath string) error {
+	config, err := pkg.ReadConfigFile(configpath)
+	if err != nil {
+		return err
+	}
+
+	encpath := path.Join(path.Dir(configpath), pkg.EncryptedCredentialsFile)
+	credentials, err := pkg.LoadEncryptedCredentials(encpath)
+	if err != nil {
+		return err
+	}
+
+	// an alias defined in both places with different values would lose one of them
+	conflicts := []string{}
+	for alias, user := range credentials {
+		if existing, ok := config.Credentials[alias]; ok && !reflect.DeepEqual(existing, user) {
+			conflicts = append(conflicts, alias)
 		}
 	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("credentials defined both in %s and %s: %s, rename or remove them first",
+			configpath, encpath, strings.Join(conflicts, ", "))
+	}
+
+	if config.Credentials == nil {
+		config.Credentials = map[string]pkg.User{}
+	}
+	for alias, user := range credentials {
+		config.Credentials[alias] = user
+	}
+
+	if err := writeConfig(configpath, config); err != nil {
+		return err
+	}
+	slog.Info("Credentials decrypted", "file", configpath, "count", len(credentials))
+	return os.Remove(encpath)
 }
 
-func readCsv(cnf pkg.Config, filepath string) (err error) {
-	f, err := os.Open(filepath)
+func newPassphrase() (string, error) {
+	if value, ok := os.LookupEnv(pkg.PassphraseEnv); ok {
+		return value, nil
+	}
+
+	passphrase, err := terminal.ReadPassword("New master passphrase: ")
 	if err != nil {
-		return
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("empty passphrase")
 	}
-	defer f.Close()
-	csvReader := csv.NewReader(f)
-	header, err := csvReader.Read()
 
+	confirm, err := terminal.ReadPassword("Repeat master passphrase: ")
 	if err != nil {
-		return
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+	return passphrase, nil
+}
+
+// readCsv reads the connections of a csv file, the header names the columns. Besides the fields of a
+// database entry (user is the credential alias, tag a comma separated list) username and password
+// create a credential, a row cannot have both user and username. All the invalid rows are reported together, with their line number.
+func readCsv(r io.Reader) ([]importedConnection, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the csv header: %w", err)
+	}
+
+	var errs []error
+	for i, hdr := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(hdr))
+		switch {
+		case slices.Contains([]string{"alias", "username", "password", "url", "host", "port", "user", "database", "tunnel", "driver", "tag"}, header[i]):
+		case strings.HasPrefix(header[i], "params."), strings.HasPrefix(header[i], "tls."):
+		default:
+			errs = append(errs, fmt.Errorf("line 1: unknown column %q", hdr))
+		}
+	}
+	if !slices.Contains(header, "alias") {
+		errs = append(errs, fmt.Errorf("line 1: missing alias column"))
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
 
+	connections := []importedConnection{}
+	seen := map[string]int{}
 	for {
 		line, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				break
-			}
-			return err
+			errs = append(errs, err)
+			continue
 		}
+		lineno, _ := csvReader.FieldPos(0)
 
-		alias := ""
-		info := pkg.ConnectionInfo{}
+		if len(line) != len(header) {
+			errs = append(errs, fmt.Errorf("line %d: expected %d fields, got %d", lineno, len(header), len(line)))
+			continue
+		}
 
+		c := importedConnection{}
+		rowErr := error(nil)
 		for i, hdr := range header {
-			value := line[i]
-			if hdr == "alias" {
-				alias = value
-			} else if hdr == "host" {
-				info.Host = value
-			} else if hdr == "port" {
-				info.Port, err = strconv.Atoi(value)
-				if err != nil {
-					return err
+			value := strings.TrimSpace(line[i])
+			switch {
+			case value == "":
+			case hdr == "alias":
+				c.Alias = value
+			case hdr == "username":
+				c.User.Username = value
+			case hdr == "password":
+				c.User.Password = value
+			default:
+				if err := setField(&c.Info, hdr, value); err != nil && rowErr == nil {
+					rowErr = fmt.Errorf("%s: %w", hdr, err)
 				}
-			} else if hdr == "database" {
-				info.Database = value
-			} else if hdr == "tunnel" {
-				info.Tunnel = value
-			} else if hdr == "user" {
-				info.UserAlias = value
-			} else if hdr == "driver" {
-				info.Driver = value
 			}
 		}
 
-		cnf.Databases[alias] = info
+		if rowErr == nil && c.Alias == "" {
+			rowErr = fmt.Errorf("missing alias")
+		}
+		if rowErr == nil {
+			rowErr = checkConnection(c.Info)
+		}
+		if rowErr == nil && c.Info.UserAlias != "" && c.User.Username != "" {
+			rowErr = fmt.Errorf("both user and username are set")
+		}
+		if rowErr == nil && c.User.Password != "" && c.User.Username == "" {
+			rowErr = fmt.Errorf("password without username")
+		}
+		if rowErr == nil && seen[c.Alias] != 0 {
+			rowErr = fmt.Errorf("alias %s already defined on line %d", c.Alias, seen[c.Alias])
+		}
+		if rowErr != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", lineno, rowErr))
+			continue
+		}
+
+		seen[c.Alias] = lineno
+		connections = append(connections, c)
 	}
-	return
+	return connections, errors.Join(errs...)
 }
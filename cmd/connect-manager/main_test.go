@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestDecryptCredentials(t *testing.T) {
+	t.Setenv(pkg.PassphraseEnv, "secret passphrase")
+
+	tests := []struct {
+		name      string
+		plaintext map[string]pkg.User
+		err       string
+	}{
+		{name: "no plaintext credentials"},
+		{name: "same credential", plaintext: map[string]pkg.User{"prod": {Username: "admin", Password: "x"}}},
+		{
+			name:      "conflicting credential",
+			plaintext: map[string]pkg.User{"prod": {Username: "admin", Password: "y"}, "dev": {Username: "dev"}},
+			err:       "prod",
+		},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		configpath := filepath.Join(dir, "config.yaml")
+		encpath := filepath.Join(dir, pkg.EncryptedCredentialsFile)
+
+		data, err := pkg.EncryptCredentials(map[string]pkg.User{"prod": {Username: "admin", Password: "x"}}, "secret passphrase")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(encpath, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeConfig(configpath, pkg.Config{Credentials: tt.plaintext}); err != nil {
+			t.Fatal(err)
+		}
+
+		err = decryptCredentials(configpath)
+		_, statErr := os.Stat(encpath)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: decryptCredentials() error = %v, expected %q", tt.name, err, tt.err)
+			}
+			if statErr != nil {
+				t.Errorf("%s: expected %s to be kept, got %v", tt.name, encpath, statErr)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: decryptCredentials() error = %v", tt.name, err)
+			continue
+		}
+		if statErr == nil {
+			t.Errorf("%s: expected %s to be removed", tt.name, encpath)
+		}
+		config, err := pkg.ReadConfigFile(configpath)
+		if err != nil || config.Credentials["prod"].Password != "x" {
+			t.Errorf("%s: expected the credential in config.yaml, got %+v, %v", tt.name, config.Credentials, err)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// ModeWrite executes every statement immediately
+	ModeWrite = "write"
+	// ModeConfirm previews write statements and executes them only when called again with the returned token
+	ModeConfirm = "confirm"
+	// ModeReadOnly rejects every write statement
+	ModeReadOnly = "read-only"
+
+	confirmationTTL = 5 * time.Minute
+)
+
+var (
+	updateRe = regexp.MustCompile(`(?is)^\s*update\s+(.+?)\s+set\s+(.+?)(\s+where\s+.+?)?\s*;?\s*$`)
+	deleteRe = regexp.MustCompile(`(?is)^\s*delete\s+from\s+(.+?)(\s+where\s+.+?)?\s*;?\s*$`)
+	limitRe  = regexp.MustCompile(`(?is)\s+(order\s+by\s+.+?)?(limit\s+\d+)?\s*$`)
+)
+
+// ValidMode reports whether mode is one of the supported -mode values
+func ValidMode(mode string) bool {
+	return mode == ModeWrite || mode == ModeConfirm || mode == ModeReadOnly
+}
+
+// writeKeywords start the data changing statements a WITH clause can wrap
+var writeKeywords = []string{"INSERT", "UPDATE", "DELETE", "REPLACE", "MERGE"}
+
+// isReadQuery reports whether the statement only reads data and can be run with Query instead of Exec.
+// Comments are ignored, and every statement of the query must be a read.
+func isReadQuery(query string) bool {
+	statement := []string{}
+	for _, token := range append(queryTokenRe.FindAllString(normalizeQuery(query), -1), ";") {
+		if token != ";" {
+			statement = append(statement, strings.ToUpper(token))
+			continue
+		}
+		if !isReadStatement(statement) {
+			return false
+		}
+		statement = statement[:0]
+	}
+	return true
+}
+
+// returnsRows reports whether the query must run with Query to return its rows: the reads, and
+// EXPLAIN ANALYZE which is a write for the guard but returns the plan
+func returnsRows(query string) bool {
+	if isReadQuery(query) {
+		return true
+	}
+	tokens := queryTokenRe.FindAllString(normalizeQuery(query), -1)
+	for len(tokens) > 0 && tokens[0] == "(" {
+		tokens = tokens[1:]
+	}
+	return len(tokens) > 0 && strings.EqualFold(tokens[0], "EXPLAIN")
+}
+
+// isReadStatement classifies the upper case tokens of a statement by its first keyword. EXPLAIN ANALYZE
+// runs the statement it explains and WITH can wrap a write, so they are writes when they could change data.
+func isReadStatement(tokens []string) bool {
+	for len(tokens) > 0 && tokens[0] == "(" {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return true
+	}
+
+	switch tokens[0] {
+	case "SELECT", "SHOW", "DESCRIBE", "DESC", "HELP":
+		return true
+	case "EXPLAIN":
+		return !slices.Contains(tokens, "ANALYZE")
+	case "WITH":
+		return !slices.ContainsFunc(tokens, func(token string) bool {
+			return slices.Contains(writeKeywords, token)
+		})
+	}
+	return false
+}
+
+type pendingWrite struct {
+	query   string
+	params  []any
+	expires time.Time
+}
+
+// WriteGuard decides how write statements coming from execute_query are handled according to the server mode
+type WriteGuard struct {
+	Mode string
+
+	mu      sync.Mutex
+	pending map[string]pendingWrite
+}
+
+func NewWriteGuard(mode string) *WriteGuard {
+	return &WriteGuard{
+		Mode:    mode,
+		pending: map[string]pendingWrite{},
+	}
+}
+
+// issue stores the statement and returns a one time token required to execute it
+func (g *WriteGuard) issue(query string, params []any) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for t, p := range g.pending {
+		if now.After(p.expires) {
+			delete(g.pending, t)
+		}
+	}
+
+	g.pending[token] = pendingWrite{
+		query:   query,
+		params:  params,
+		expires: now.Add(confirmationTTL),
+	}
+	return token, nil
+}
+
+// redeem consumes the token, checking it was issued for exactly the same statement and params
+func (g *WriteGuard) redeem(token, query string, params []any) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	p, ok := g.pending[token]
+	if !ok {
+		return fmt.Errorf("unknown or already used confirmation token")
+	}
+	delete(g.pending, token)
+
+	if time.Now().After(p.expires) {
+		return fmt.Errorf("confirmation token expired, request a new preview")
+	}
+	if p.query != query || !reflect.DeepEqual(p.params, params) {
+		return fmt.Errorf("confirmation token was issued for a different query")
+	}
+	return nil
+}
+
+// countRewrite turns a single table UPDATE or DELETE into a SELECT COUNT(*) over the same rows.
+// It returns the rewritten query and the params still referenced by it (placeholders of the SET clause are dropped).
+func countRewrite(query string, params []any) (string, []any, bool) {
+	if m := updateRe.FindStringSubmatch(query); m != nil {
+		skip := strings.Count(m[1], "?") + strings.Count(m[2], "?")
+		if skip > len(params) {
+			return "", nil, false
+		}
+		where := limitRe.ReplaceAllString(m[3], "")
+		return fmt.Sprintf("SELECT COUNT(*) AS affected FROM %s%s", m[1], where), params[skip:], true
+	}
+
+	if m := deleteRe.FindStringSubmatch(query); m != nil {
+		skip := strings.Count(m[1], "?")
+		if skip > len(params) {
+			return "", nil, false
+		}
+		where := limitRe.ReplaceAllString(m[2], "")
+		return fmt.Sprintf("SELECT COUNT(*) AS affected FROM %s%s", m[1], where), params[skip:], true
+	}
+
+	return "", nil, false
+}
+
+// estimateAffectedRows tries a COUNT(*) rewrite first and falls back to the row estimate reported by EXPLAIN
+func estimateAffectedRows(ctx context.Context, db *sql.DB, query string, params []any) (*int64, string) {
+	if countQuery, countParams, ok := countRewrite(query, params); ok {
+		results, err := readOnlyQuery(ctx, db, countQuery, countParams...)
+		if err == nil && len(results) == 1 {
+			count := parseCount(results[0]["affected"])
+			return &count, "count"
+		}
+	}
+
+	results, err := readOnlyQuery(ctx, db, "EXPLAIN "+query, params...)
+	if err != nil {
+		return nil, "unavailable"
+	}
+
+	var total int64
+	for _, row := range results {
+		total += parseCount(row["rows"])
+	}
+	return &total, "explain"
+}
+
+// preview estimates the rows a write statement would affect and issues the token needed to execute it
+func (g *WriteGuard) preview(ctx context.Context, db *sql.DB, query string, params []any) (string, error) {
+	token, err := g.issue(query, params)
+	if err != nil {
+		return "", err
+	}
+
+	estimate, method := estimateAffectedRows(ctx, db, query, params)
+
+	type writePreview struct {
+		Status            string `json:"status"`
+		Query             string `json:"query"`
+		EstimatedRows     *int64 `json:"estimated_rows"`
+		EstimateMethod    string `json:"estimate_method"`
+		ConfirmationToken string `json:"confirmation_token"`
+		ExpiresIn         string `json:"expires_in"`
+	}
+
+	bytes, err := json.MarshalIndent(writePreview{
+		Status:            "confirmation_required",
+		Query:             query,
+		EstimatedRows:     estimate,
+		EstimateMethod:    method,
+		ConfirmationToken: token,
+		ExpiresIn:         confirmationTTL.String(),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// executeInTx runs a write statement inside a transaction, rolling back when more than maxAffected rows are changed
+func executeInTx(ctx context.Context, db *sql.DB, query string, params []any, maxAffected int64) (string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if returnsRows(query) {
+		rows, err := tx.QueryContext(ctx, query, params...)
+		if err != nil {
+			return "", err
+		}
+		results, err := scanRows(rows)
+		rows.Close()
+		if err != nil {
+			return "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		return resultsToJSON(results)
+	}
+
+	res, err := tx.ExecContext(ctx, query, params...)
+	if err != nil {
+		return "", err
+	}
+
+	rowsAffected, _ := res.RowsAffected()
+	if maxAffected > 0 && rowsAffected > maxAffected {
+		return "", fmt.Errorf("statement affected %d rows, more than max_affected_rows %d: rolled back", rowsAffected, maxAffected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return writeResultJSON(res), nil
+}
+
+func writeResultJSON(res sql.Result) string {
+	rowsAffected, _ := res.RowsAffected()
+	lastInsertId, _ := res.LastInsertId()
+	return fmt.Sprintf(`{"rows_affected": %d, "last_insert_id": %d}`, rowsAffected, lastInsertId)
+}
+
+// Execute applies the guard mode to the statement: reads always run, writes are rejected, previewed or executed
+func (g *WriteGuard) Execute(ctx context.Context, db *sql.DB, query string, params []any, token string, maxAffected int64) (string, error) {
+	if isReadQuery(query) {
+		if g.Mode == ModeReadOnly {
+			results, err := readOnlyQuery(ctx, db, query, params...)
+			if err != nil {
+				return "", err
+			}
+			return resultsToJSON(results)
+		}
+		return executeSQLToJSON(ctx, db, query, params...)
+	}
+
+	switch g.Mode {
+	case ModeReadOnly:
+		return "", fmt.Errorf("server is running in read-only mode, write statements are not allowed")
+
+	case ModeConfirm:
+		if token == "" {
+			return g.preview(ctx, db, query, params)
+		}
+		if err := g.redeem(token, query, params); err != nil {
+			return "", err
+		}
+		return executeInTx(ctx, db, query, params, maxAffected)
+
+	default:
+		if maxAffected > 0 {
+			return executeInTx(ctx, db, query, params, maxAffected)
+		}
+		return executeSQLToJSON(ctx, db, query, params...)
+	}
+}
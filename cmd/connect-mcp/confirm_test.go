@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCountRewrite(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		params         []any
+		expectedQuery  string
+		expectedParams []any
+		ok             bool
+	}{
+		{
+			name:          "Update with where",
+			query:         "UPDATE users SET active = 0 WHERE last_login < '2020-01-01';",
+			expectedQuery: "SELECT COUNT(*) AS affected FROM users WHERE last_login < '2020-01-01'",
+			ok:            true,
+		},
+		{
+			name:           "Update with placeholders",
+			query:          "update users set name = ? where id = ?",
+			params:         []any{"bob", int64(3)},
+			expectedQuery:  "SELECT COUNT(*) AS affected FROM users where id = ?",
+			expectedParams: []any{int64(3)},
+			ok:             true,
+		},
+		{
+			name:          "Delete without where",
+			query:         "DELETE FROM logs",
+			expectedQuery: "SELECT COUNT(*) AS affected FROM logs",
+			ok:            true,
+		},
+		{
+			name:           "Delete with order and limit",
+			query:          "DELETE FROM logs WHERE level = ? ORDER BY id LIMIT 10",
+			params:         []any{"debug"},
+			expectedQuery:  "SELECT COUNT(*) AS affected FROM logs WHERE level = ?",
+			expectedParams: []any{"debug"},
+			ok:             true,
+		},
+		{
+			name:  "Insert is not rewritten",
+			query: "INSERT INTO logs (level) VALUES ('info')",
+			ok:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		gotQuery, gotParams, ok := countRewrite(tt.query, tt.params)
+		if ok != tt.ok {
+			t.Errorf("%s: countRewrite() ok = %v, expected %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if gotQuery != tt.expectedQuery {
+			t.Errorf("%s: countRewrite() query = %q, expected %q", tt.name, gotQuery, tt.expectedQuery)
+		}
+		if len(gotParams) != 0 || len(tt.expectedParams) != 0 {
+			if !reflect.DeepEqual(gotParams, tt.expectedParams) {
+				t.Errorf("%s: countRewrite() params = %#v, expected %#v", tt.name, gotParams, tt.expectedParams)
+			}
+		}
+	}
+}
+
+func TestIsReadQuery(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"select 1", true},
+		{"  SHOW TABLES;", true},
+		{"explain select * from users", true},
+		{"update users set a = 1", false},
+		{"INSERT INTO users VALUES (1)", false},
+		{"", true},
+		{"-- comment\nselect 1", true},
+		{"/* select */ delete from users", false},
+		{"# select\ndelete from users", false},
+		{"(select 1) union (select 2)", true},
+		{"explain analyze select * from users", false},
+		{"EXPLAIN (ANALYZE) DELETE FROM users", false},
+		{"explain delete from users", true},
+		{"with recent as (select id from users) select * from recent", true},
+		{"WITH old AS (SELECT id FROM users) DELETE FROM users WHERE id IN (SELECT id FROM old)", false},
+		{"with t as (update users set a = 1 returning id) select * from t", false},
+		{"select 1; delete from users", false},
+		{"select 'delete' from users", true},
+	}
+
+	for _, tt := range tests {
+		got := isReadQuery(tt.query)
+		if got != tt.expected {
+			t.Errorf("isReadQuery(%q) = %v; expected %v", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestReturnsRows(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"select 1", true},
+		{"/* plan */ explain analyze select * from users", true},
+		{"with old as (select id from users) delete from users where id in (select id from old)", false},
+		{"delete from users", false},
+	}
+
+	for _, tt := range tests {
+		got := returnsRows(tt.query)
+		if got != tt.expected {
+			t.Errorf("returnsRows(%q) = %v; expected %v", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestConfirmationToken(t *testing.T) {
+	g := NewWriteGuard(ModeConfirm)
+	query := "DELETE FROM logs WHERE id = ?"
+
+	token, err := g.issue(query, []any{int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := g.redeem(token, query, []any{int64(2)}); err == nil {
+		t.Error("expected error when params differ from the previewed ones")
+	}
+
+	token, err = g.issue(query, []any{int64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.redeem(token, query, []any{int64(1)}); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if err := g.redeem(token, query, []any{int64(1)}); err == nil {
+		t.Error("expected error when reusing a confirmation token")
+	}
+}
+
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	g := NewWriteGuard(ModeReadOnly)
+
+	_, err := g.Execute(context.Background(), nil, "DELETE FROM logs", nil, "", 0)
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("expected read-only error, got %v", err)
+	}
+}
+
+func TestValidMode(t *testing.T) {
+	for _, mode := range []string{ModeWrite, ModeConfirm, ModeReadOnly} {
+		if !ValidMode(mode) {
+			t.Errorf("expected %q to be a valid mode", mode)
+		}
+	}
+	if ValidMode("readonly") {
+		t.Error("expected readonly to be rejected")
+	}
+}
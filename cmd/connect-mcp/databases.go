@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// target is a database exposed by the server, with the policy and write guard applied to it
+type target struct {
+	DB     *sql.DB
+	Info   pkg.ConnectionInfo
+	Guard  *WriteGuard
+	Policy pkg.Policy
+}
+
+// targets maps the exposed aliases to their databases, a tag exposes more than one
+type targets map[string]*target
+
+func (t targets) aliases() []string {
+	aliases := []string{}
+	for alias := range t {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+// toolOptions adds the database argument to a tool when the server exposes more than one alias
+func (t targets) toolOptions(opts ...mcp.ToolOption) []mcp.ToolOption {
+	if len(t) > 1 {
+		opts = append(opts, mcp.WithString("database",
+			mcp.Required(),
+			mcp.Description("Alias of the database to use, as returned by list_databases"),
+			mcp.Enum(t.aliases()...),
+		))
+	}
+	return opts
+}
+
+// pick returns the database selected by the database argument, which is optional with a single alias
+func (t targets) pick(request mcp.CallToolRequest) (*target, error) {
+	alias := request.GetString("database", "")
+	if alias == "" && len(t) == 1 {
+		for _, only := range t {
+			return only, nil
+		}
+	}
+	if alias == "" {
+		return nil, fmt.Errorf("missing required database argument, expected one of %v", t.aliases())
+	}
+
+	db, ok := t[alias]
+	if !ok {
+		return nil, fmt.Errorf("unknown database %q, expected one of %v", alias, t.aliases())
+	}
+	return db, nil
+}
+
+// listDatabases describes the exposed aliases
+func (t targets) listDatabases() (string, error) {
+	type databaseEntry struct {
+		Alias    string   `json:"alias"`
+		Database string   `json:"database"`
+		Driver   string   `json:"driver"`
+		Tag      []string `json:"tag,omitempty"`
+	}
+
+	entries := []databaseEntry{}
+	for _, alias := range t.aliases() {
+		info := t[alias].Info
+		entries = append(entries, databaseEntry{Alias: alias, Database: info.Database, Driver: info.Driver, Tag: info.Tag})
+	}
+
+	bytes, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// openDatabase resolves the alias, starting its ssh tunnel, and checks that the database answers.
+// The tunnel listener stays open for the lifetime of the server, unless the database fails.
+func openDatabase(config pkg.Config, alias string) (db *sql.DB, info pkg.ConnectionInfo, err error) {
+	info, userAlias, err := config.Lookup(alias)
+	if err != nil {
+		return nil, info, fmt.Errorf("unable to resolve connection: %w", err)
+	}
+	// the tools query the MySQL catalog, quote identifiers with backticks and bind ? placeholders
+	if info.Driver != pkg.DriverMySQL {
+		return nil, info, fmt.Errorf("unsupported driver %q for %s: connect-mcp only supports mysql databases", info.Driver, alias)
+	}
+	slog.Info("Starting MCP connection to", "alias", alias, "host", info.Host, "db", info.Database)
+
+	var tunnel net.Listener
+	defer func() {
+		if err != nil && tunnel != nil {
+			tunnel.Close()
+		}
+	}()
+
+	if info.Tunnel != "" {
+		sshUser, sshAddr, err := pkg.ParseTunnel(info.Tunnel)
+		if err != nil {
+			return nil, info, err
+		}
+
+		agent, err := pkg.AuthAgent()
+		if err != nil {
+			return nil, info, fmt.Errorf("unable to connect to ssh agent: %w", err)
+		}
+
+		// the system picks a free port, several databases can be tunneled at once
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, info, fmt.Errorf("failed to start local listener: %w", err)
+		}
+		tunnel = listener
+		localPort := listener.Addr().(*net.TCPAddr).Port
+		slog.Info("Starting tunnel", "host", info.Tunnel, "port", info.Port, "localport", localPort)
+
+		go pkg.TunnelInfo{
+			User:       sshUser,
+			SshAddr:    sshAddr,
+			RemoteAddr: fmt.Sprintf("%s:%d", info.Host, info.Port),
+			Agent:      agent,
+		}.Start(listener)
+
+		info.Host = "127.0.0.1"
+		info.Port = localPort
+	}
+
+	password, err := userAlias.ResolvePassword()
+	if err != nil {
+		return nil, info, fmt.Errorf("unable to read password: %w", err)
+	}
+
+	dsn, err := pkg.Connection{
+		Username: userAlias.Username,
+		Password: password,
+		Host:     info.Host,
+		Port:     info.Port,
+		Database: info.Database,
+		Params:   info.Params,
+		TLS:      info.TLS,
+	}.DSN(info.Driver)
+	if err != nil {
+		return nil, info, fmt.Errorf("invalid connection parameters: %w", err)
+	}
+
+	db, err = sql.Open(info.Driver, dsn)
+	if err != nil {
+		return nil, info, err
+	}
+
+	slog.Info("pinging the database", "alias", alias)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, info, err
+	}
+	return db, info, nil
+}
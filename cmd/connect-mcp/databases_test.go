@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestTargetsPick(t *testing.T) {
+	single := targets{"sales": {Info: pkg.ConnectionInfo{Database: "sales"}}}
+	group := targets{
+		"sales":   {Info: pkg.ConnectionInfo{Database: "sales"}},
+		"billing": {Info: pkg.ConnectionInfo{Database: "billing"}},
+	}
+
+	tests := []struct {
+		name      string
+		databases targets
+		arguments map[string]any
+		expected  string
+		err       string
+	}{
+		{name: "single without argument", databases: single, expected: "sales"},
+		{name: "single with argument", databases: single, arguments: map[string]any{"database": "sales"}, expected: "sales"},
+		{name: "group", databases: group, arguments: map[string]any{"database": "billing"}, expected: "billing"},
+		{name: "group without argument", databases: group, err: "missing required database argument"},
+		{name: "unknown", databases: group, arguments: map[string]any{"database": "hr"}, err: `unknown database "hr"`},
+	}
+
+	for _, tt := range tests {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = tt.arguments
+
+		got, err := tt.databases.pick(request)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: pick() error = %v, expected %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got.Info.Database != tt.expected {
+			t.Errorf("%s: pick() = %+v, %v, expected %s", tt.name, got, err, tt.expected)
+		}
+	}
+}
+
+func TestTargetsToolOptions(t *testing.T) {
+	single := targets{"sales": {}}
+	tool := mcp.NewTool("list_tables", single.toolOptions()...)
+	if _, ok := tool.InputSchema.Properties["database"]; ok {
+		t.Errorf("toolOptions() added the database argument with a single alias")
+	}
+
+	group := targets{"sales": {}, "billing": {}}
+	tool = mcp.NewTool("list_tables", group.toolOptions()...)
+	property, ok := tool.InputSchema.Properties["database"].(map[string]any)
+	if !ok {
+		t.Fatalf("toolOptions() = %+v, expected a database argument", tool.InputSchema)
+	}
+	if !reflect.DeepEqual(property["enum"], []string{"billing", "sales"}) {
+		t.Errorf("toolOptions() enum = %v, expected the sorted aliases", property["enum"])
+	}
+	if !reflect.DeepEqual(tool.InputSchema.Required, []string{"database"}) {
+		t.Errorf("toolOptions() required = %v, expected database", tool.InputSchema.Required)
+	}
+}
+
+func TestOpenDatabaseUnsupportedDriver(t *testing.T) {
+	config := pkg.Config{Databases: map[string]pkg.ConnectionInfo{
+		"local": {Driver: pkg.DriverSQLite, Database: ":memory:"},
+	}}
+
+	_, _, err := openDatabase(config, "local")
+	if err == nil || !strings.Contains(err.Error(), "only supports mysql") {
+		t.Errorf("openDatabase() error = %v, expected the sqlite driver to be rejected", err)
+	}
+}
@@ -6,9 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
-	"math/rand"
-	"net"
+	"math"
 	"os"
+	"slices"
 	"strings"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -20,22 +20,27 @@ import (
 
 var version string = "?"
 
+const usage = "connect-mcp [-http <host:port>] [-mode write|confirm|read-only] [-tag <tag>] <alias>"
+
 func main() {
 	// Configure slog to output strictly to os.Stderr
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	})))
 
-	config, err := pkg.LoadConfig(pkg.ConfigPath("config.yaml"))
+	config, err := pkg.LoadConfig(pkg.DefaultConfigPath())
 	if err != nil {
 		slog.Error("Failed to read config file", "err", err)
 		os.Exit(1)
 	}
 
 	httpOpt := ""
+	mode := ModeWrite
 	alias := ""
+	tag := ""
+	completions := false
 
-	// Manual parsing of -http / --http to keep --completions and -v clean
+	// Manual parsing of -http / --http and -mode / --mode to keep --completions and -v clean
 	for i := 1; i < len(os.Args); i++ {
 		arg := os.Args[i]
 		if arg == "-http" || arg == "--http" {
@@ -46,27 +51,40 @@ func main() {
 				slog.Error("Opzione -http richiede un valore (es. :8000)")
 				os.Exit(1)
 			}
-		} else if strings.HasPrefix(arg, "-") && arg != "-v" && arg != "--version" && arg != "--completions" {
+		} else if arg == "-mode" || arg == "--mode" {
+			if i+1 < len(os.Args) && ValidMode(os.Args[i+1]) {
+				mode = os.Args[i+1]
+				i++ // skip the value
+			} else {
+				slog.Error("Opzione -mode richiede un valore tra write, confirm, read-only")
+				os.Exit(1)
+			}
+		} else if arg == "-tag" || arg == "--tag" {
+			if i+1 < len(os.Args) {
+				tag = os.Args[i+1]
+				i++ // skip the value
+			} else {
+				slog.Error("Opzione -tag richiede un valore")
+				os.Exit(1)
+			}
+		} else if arg == "--completions" {
+			completions = true
+		} else if strings.HasPrefix(arg, "-") && arg != "-v" && arg != "--version" {
 			slog.Error("Opzione non riconosciuta", "opt", arg)
-			fmt.Fprintf(os.Stderr, "Usage: connect-mcp [-http <host:port>] <alias>\n")
+			fmt.Fprintf(os.Stderr, "Usage: %s\n", usage)
 			os.Exit(1)
 		} else {
 			alias = arg
 		}
 	}
 
-	if alias == "" {
-		slog.Error("alias obbligatorio per collegarsi a db in modalità MCP")
-		fmt.Fprintf(os.Stderr, "Usage: connect-mcp [-http <host:port>] <alias>\n")
-		os.Exit(1)
-	}
-
-	if alias == "--completions" {
-		aliases := []string{}
-		for name := range config.Databases {
-			aliases = append(aliases, name)
+	if completions {
+		// "--completions tags" lists the tags, used after --tag
+		if alias == "tags" {
+			fmt.Printf("%s", strings.Join(config.Tags(), " "))
+		} else {
+			fmt.Printf("%s", strings.Join(config.Aliases(""), " "))
 		}
-		fmt.Printf("%s", strings.Join(aliases, " "))
 		os.Exit(0)
 	}
 
@@ -75,69 +93,50 @@ func main() {
 		os.Exit(0)
 	}
 
-	info, ok := config.Databases[alias]
-	if !ok {
-		slog.Error("Alias not found in config file", "alias", alias)
+	if alias == "" && tag == "" {
+		slog.Error("alias obbligatorio per collegarsi a db in modalità MCP")
+		fmt.Fprintf(os.Stderr, "Usage: %s\n", usage)
 		os.Exit(1)
 	}
-	slog.Info("Starting MCP connection to", "host", info.Host, "db", info.Database)
 
-	if info.Tunnel != "" {
-		randomPort := rand.Intn(1000) + 9000
-		slog.Info("Starting tunnel", "host", info.Tunnel, "port", info.Port, "localport", randomPort)
-		agent, err := pkg.AuthAgent()
-		if err != nil {
-			slog.Error("unable to connect to ssh agent", "err", err)
+	// with a tag and no alias, every database with the tag is exposed
+	aliases := []string{alias}
+	if alias == "" {
+		aliases = config.Aliases(tag)
+		if len(aliases) == 0 {
+			slog.Error("no database tagged", "tag", tag)
 			os.Exit(1)
 		}
+	} else if tag != "" && !slices.Contains(config.Aliases(tag), alias) {
+		slog.Error("database not tagged", "alias", alias, "tag", tag)
+		os.Exit(1)
+	}
 
-		localAddr := fmt.Sprintf("127.0.0.1:%d", randomPort)
-		listener, err := net.Listen("tcp", localAddr)
+	// a database failing is left out, the others of the group are still exposed
+	databases := targets{}
+	for _, alias := range aliases {
+		db, info, err := openDatabase(config, alias)
 		if err != nil {
-			slog.Error("failed to start local listener", "err", err)
-			os.Exit(1)
+			slog.Error("Impossibile stabilire connessione a database", "alias", alias, "err", err)
+			continue
 		}
-		defer listener.Close()
-
-		values := strings.SplitN(info.Tunnel, "@", 2)
-		go pkg.TunnelInfo{
-			User:       values[0],
-			SshAddr:    fmt.Sprintf("%s:22", values[1]),
-			RemoteAddr: fmt.Sprintf("%s:%d", info.Host, info.Port),
-			Agent:      agent,
-		}.Start(listener)
-
-		info.Host = "127.0.0.1"
-		info.Port = randomPort
-	}
+		defer db.Close()
 
-	userAlias, ok := config.Credentials[info.UserAlias]
-	if !ok {
-		slog.Error("alias not configured", "alias", info.UserAlias)
-		os.Exit(1)
-	}
-
-	db, err := sql.Open(info.Driver, pkg.Connection{
-		Username: userAlias.Username,
-		Password: userAlias.Password,
-		Host:     info.Host,
-		Port:     info.Port,
-		Database: info.Database,
-	}.Connstring())
-	if err != nil {
-		slog.Error("Impossibile stabilire connessione a database", "err", err)
-		os.Exit(1)
+		databases[alias] = &target{
+			DB:     db,
+			Info:   info,
+			Guard:  NewWriteGuard(mode),
+			Policy: config.Policy.Merge(info.Policy),
+		}
 	}
-	defer db.Close()
 
-	slog.Info("pinging the database")
-	err = db.Ping()
-	if err != nil {
-		slog.Error(err.Error())
+	if len(databases) == 0 {
+		slog.Error("no database available", "aliases", aliases)
 		os.Exit(1)
 	}
 
-	err = StartMcpServer(db, info.Database, httpOpt)
+	slog.Info("Write mode", "mode", mode)
+	err = StartMcpServer(databases, config.Options.AutoLimit, httpOpt)
 	if err != nil {
 		slog.Error("MCP server failed", "err", err)
 		os.Exit(1)
@@ -145,8 +144,8 @@ func main() {
 }
 
 // StartMcpServer starts the MCP server in stdio mode by default, or in HTTP (SSE) mode if httpOpt is specified.
-func StartMcpServer(db *sql.DB, schemaName string, httpOpt string) error {
-	s := createMcpServer(db, schemaName)
+func StartMcpServer(databases targets, rowLimit int, httpOpt string) error {
+	s := createMcpServer(databases, rowLimit)
 
 	if httpOpt == "" {
 		slog.Info("Starting MCP server in stdio mode")
@@ -176,7 +175,7 @@ func StartMcpServer(db *sql.DB, schemaName string, httpOpt string) error {
 	return nil
 }
 
-func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
+func createMcpServer(databases targets, rowLimit int) *server.MCPServer {
 	// Create a new MCP server
 	s := server.NewMCPServer(
 		"connect-mysql-mcp",
@@ -184,12 +183,21 @@ func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
 	)
 
 	// 1. Tool: list_tables
-	listTablesTool := mcp.NewTool("list_tables",
+	listTablesTool := mcp.NewTool("list_tables", databases.toolOptions(
 		mcp.WithDescription("List all tables in the connected MySQL database"),
-	)
+	)...)
 	s.AddTool(listTablesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, policy := target.DB, target.Policy
+
 		slog.Info("MCP call: list_tables")
-		jsonStr, err := executeSQLToJSON(db, "SHOW TABLES;")
+		jsonStr, err := executeSQLToJSON(ctx, db, "SHOW TABLES;")
+		if err == nil {
+			jsonStr, err = applyPolicyJSON(policy, nil, jsonStr)
+		}
 		if err != nil {
 			slog.Error("Failed to list tables", "err", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Error listing tables: %v", err)), nil
@@ -198,14 +206,20 @@ func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
 	})
 
 	// 2. Tool: describe_table
-	describeTableTool := mcp.NewTool("describe_table",
-		mcp.WithDescription("Get column definitions, types, keys, and default values for a specific table"),
+	describeTableTool := mcp.NewTool("describe_table", databases.toolOptions(
+		mcp.WithDescription("Get column definitions, types, default values, primary key, indexes and foreign keys (in both directions) for a specific table"),
 		mcp.WithString("table_name",
 			mcp.Required(),
 			mcp.Description("The name of the table to describe"),
 		),
-	)
+	)...)
 	s.AddTool(describeTableTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, schemaName, policy := target.DB, target.Info.Database, target.Policy
+
 		tableName, err := request.RequireString("table_name")
 		if err != nil {
 			slog.Error("Missing table_name argument", "err", err)
@@ -213,7 +227,10 @@ func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
 		}
 
 		slog.Info("MCP call: describe_table", "table", tableName)
-		jsonStr, err := describeTable(db, schemaName, tableName)
+		if err := checkTables(policy, []string{tableName}); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jsonStr, err := describeTable(ctx, db, schemaName, tableName, policy)
 		if err != nil {
 			slog.Error("Failed to describe table", "table", tableName, "err", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Error describing table %q: %v", tableName, err)), nil
@@ -222,22 +239,54 @@ func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
 	})
 
 	// 3. Tool: execute_query
-	executeQueryTool := mcp.NewTool("execute_query",
+	executeQueryTool := mcp.NewTool("execute_query", databases.toolOptions(
 		mcp.WithDescription("Execute an arbitrary raw SQL query (e.g., SELECT, INSERT, UPDATE, etc.) against the database"),
 		mcp.WithString("query",
 			mcp.Required(),
-			mcp.Description("The SQL query to execute"),
+			mcp.Description("The SQL query to execute, using ? placeholders for values"),
 		),
-	)
+		mcp.WithArray("params",
+			mcp.Description("Values bound in order to the ? placeholders of the query (numbers, strings, booleans or null)"),
+		),
+		mcp.WithString("confirmation_token",
+			mcp.Description("Token returned by the preview of a write statement, required to execute it when the server runs in confirm mode"),
+		),
+		mcp.WithNumber("max_affected_rows",
+			mcp.Description("Roll back the write statement if it changes more than this number of rows"),
+		),
+	)...)
 	s.AddTool(executeQueryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, guard, policy := target.DB, target.Guard, target.Policy
+
 		query, err := request.RequireString("query")
 		if err != nil {
 			slog.Error("Missing query argument", "err", err)
 			return mcp.NewToolResultError("missing required query argument"), nil
 		}
 
-		slog.Info("MCP call: execute_query", "query", query)
-		jsonStr, err := executeSQLToJSON(db, query)
+		params, err := bindParams(request.GetArguments()["params"])
+		if err != nil {
+			slog.Error("Invalid params argument", "err", err)
+			return mcp.NewToolResultError(fmt.Sprintf("invalid params argument: %v", err)), nil
+		}
+
+		token := request.GetString("confirmation_token", "")
+		maxAffected := int64(request.GetInt("max_affected_rows", 0))
+
+		slog.Info("MCP call: execute_query", "query", query, "params", len(params), "confirmed", token != "")
+		if err := checkQuery(policy, query); err != nil {
+			slog.Error("Query rejected by policy", "query", query, "err", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		jsonStr, err := guard.Execute(ctx, db, query, params, token, maxAffected)
+		if err == nil {
+			jsonStr, err = applyPolicyJSON(policy, nil, jsonStr)
+		}
 		if err != nil {
 			slog.Error("Failed to execute query", "query", query, "err", err)
 			return mcp.NewToolResultError(fmt.Sprintf("Error executing query: %v", err)), nil
@@ -245,72 +294,206 @@ func createMcpServer(db *sql.DB, schemaName string) *server.MCPServer {
 		return mcp.NewToolResultText(jsonStr), nil
 	})
 
+	// 4. Tool: sample_rows
+	sampleRowsTool := mcp.NewTool("sample_rows", databases.toolOptions(
+		mcp.WithDescription("Fetch a small sample of rows from a table to understand the shape of its data"),
+		mcp.WithString("table_name",
+			mcp.Required(),
+			mcp.Description("The name of the table to sample"),
+		),
+		mcp.WithNumber("n",
+			mcp.Description(fmt.Sprintf("Number of rows to return (default %d, capped by the configured row limit)", defaultSampleRows)),
+		),
+	)...)
+	s.AddTool(sampleRowsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, schemaName, policy := target.DB, target.Info.Database, target.Policy
+
+		tableName, err := request.RequireString("table_name")
+		if err != nil {
+			slog.Error("Missing table_name argument", "err", err)
+			return mcp.NewToolResultError("missing required table_name argument"), nil
+		}
+		n := request.GetInt("n", defaultSampleRows)
+
+		slog.Info("MCP call: sample_rows", "table", tableName, "n", n)
+		if err := checkTables(policy, []string{tableName}); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jsonStr, err := sampleRows(ctx, db, schemaName, tableName, n, rowLimit, policy)
+		if err != nil {
+			slog.Error("Failed to sample table", "table", tableName, "err", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Error sampling table %q: %v", tableName, err)), nil
+		}
+		return mcp.NewToolResultText(jsonStr), nil
+	})
+
+	// 5. Tool: column_stats
+	columnStatsTool := mcp.NewTool("column_stats", databases.toolOptions(
+		mcp.WithDescription("Compute distinct count, null ratio, min/max and most frequent values of a column"),
+		mcp.WithString("table_name",
+			mcp.Required(),
+			mcp.Description("The name of the table containing the column"),
+		),
+		mcp.WithString("column_name",
+			mcp.Required(),
+			mcp.Description("The name of the column to analyze"),
+		),
+		mcp.WithNumber("top",
+			mcp.Description(fmt.Sprintf("Number of most frequent values to return (default %d)", defaultTopValues)),
+		),
+	)...)
+	s.AddTool(columnStatsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, schemaName, policy := target.DB, target.Info.Database, target.Policy
+
+		tableName, err := request.RequireString("table_name")
+		if err != nil {
+			slog.Error("Missing table_name argument", "err", err)
+			return mcp.NewToolResultError("missing required table_name argument"), nil
+		}
+		columnName, err := request.RequireString("column_name")
+		if err != nil {
+			slog.Error("Missing column_name argument", "err", err)
+			return mcp.NewToolResultError("missing required column_name argument"), nil
+		}
+		top := request.GetInt("top", defaultTopValues)
+
+		slog.Info("MCP call: column_stats", "table", tableName, "column", columnName)
+		if err := checkTables(policy, []string{tableName}); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		jsonStr, err := getColumnStats(ctx, db, schemaName, tableName, columnName, top, rowLimit, policy)
+		if err != nil {
+			slog.Error("Failed to compute column stats", "table", tableName, "column", columnName, "err", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Error computing stats for %q.%q: %v", tableName, columnName, err)), nil
+		}
+		return mcp.NewToolResultText(jsonStr), nil
+	})
+
+	// 6. Tool: list_relationships
+	listRelationshipsTool := mcp.NewTool("list_relationships", databases.toolOptions(
+		mcp.WithDescription("List foreign key relationships between tables, useful to write correct joins"),
+		mcp.WithString("table_name",
+			mcp.Description("Restrict the result to keys declared on or referencing this table (default: whole schema)"),
+		),
+	)...)
+	s.AddTool(listRelationshipsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		target, err := databases.pick(request)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		db, schemaName, policy := target.DB, target.Info.Database, target.Policy
+
+		tableName := request.GetString("table_name", "")
+
+		slog.Info("MCP call: list_relationships", "table", tableName)
+		if tableName != "" {
+			if err := checkTables(policy, []string{tableName}); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		jsonStr, err := listRelationships(ctx, db, schemaName, tableName, policy)
+		if err != nil {
+			slog.Error("Failed to list relationships", "table", tableName, "err", err)
+			return mcp.NewToolResultError(fmt.Sprintf("Error listing relationships: %v", err)), nil
+		}
+		return mcp.NewToolResultText(jsonStr), nil
+	})
+
+	// 7. Tool: list_databases
+	listDatabasesTool := mcp.NewTool("list_databases",
+		mcp.WithDescription("List the databases exposed by this server, to be passed as the database argument of the other tools"),
+	)
+	s.AddTool(listDatabasesTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		slog.Info("MCP call: list_databases")
+		jsonStr, err := databases.listDatabases()
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Error listing databases: %v", err)), nil
+		}
+		return mcp.NewToolResultText(jsonStr), nil
+	})
+
 	return s
 }
 
-// executeSQLToJSON runs a SQL query and serializes the resulting rows (or rows affected) into indented JSON
-func executeSQLToJSON(db *sql.DB, query string) (string, error) {
+// bindParams converts the JSON decoded params argument into values accepted by database/sql.
+// Integral numbers are bound as int64 so that they are not sent to the server as floats.
+func bindParams(raw any) ([]any, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	values, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("params must be an array, got %T", raw)
+	}
+
+	params := make([]any, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case nil, string, bool, int64:
+			params[i] = v
+		case int:
+			params[i] = int64(v)
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				params[i] = int64(v)
+			} else {
+				params[i] = v
+			}
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				params[i] = n
+			} else if f, err := v.Float64(); err == nil {
+				params[i] = f
+			} else {
+				return nil, fmt.Errorf("param %d: invalid number %q", i+1, v)
+			}
+		default:
+			return nil, fmt.Errorf("param %d: unsupported type %T", i+1, value)
+		}
+	}
+	return params, nil
+}
+
+// executeSQLToJSON runs a SQL query, canceled with ctx, and serializes the resulting rows (or rows affected) into indented JSON
+func executeSQLToJSON(ctx context.Context, db *sql.DB, query string, params ...any) (string, error) {
 	trimmed := strings.TrimSpace(query)
 	if len(trimmed) == 0 {
 		return `{"results": []}`, nil
 	}
 
-	// Simple routing: if it is a write command, run Exec; otherwise use Query
-	firstWord := strings.ToLower(strings.Fields(trimmed)[0])
-	isSelect := firstWord == "select" || firstWord == "show" || firstWord == "describe" || firstWord == "explain" || firstWord == "desc" || firstWord == "help"
-
-	if !isSelect {
-		res, err := db.Exec(query)
+	// Simple routing: if it returns no rows, run Exec; otherwise use Query
+	if !returnsRows(trimmed) {
+		res, err := db.ExecContext(ctx, query, params...)
 		if err != nil {
 			return "", err
 		}
-		rowsAffected, _ := res.RowsAffected()
-		lastInsertId, _ := res.LastInsertId()
-		return fmt.Sprintf(`{"rows_affected": %d, "last_insert_id": %d}`, rowsAffected, lastInsertId), nil
+		return writeResultJSON(res), nil
 	}
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query, params...)
 	if err != nil {
 		return "", err
 	}
 	defer rows.Close()
 
-	cols, err := rows.Columns()
+	results, err := scanRows(rows)
 	if err != nil {
 		return "", err
 	}
+	return resultsToJSON(results)
+}
 
-	var results []map[string]any
-
-	currentRow := make([]any, len(cols))
-	for idx := range cols {
-		var i []byte
-		currentRow[idx] = &i
-	}
-
-	for rows.Next() {
-		err := rows.Scan(currentRow...)
-		if err != nil {
-			return "", err
-		}
-
-		rowMap := make(map[string]any)
-		for idx, colname := range cols {
-			ptr := currentRow[idx]
-			bPtr := ptr.(*[]byte)
-			if bPtr == nil || *bPtr == nil {
-				rowMap[colname] = nil
-			} else {
-				rowMap[colname] = string(*bPtr)
-			}
-		}
-		results = append(results, rowMap)
-	}
-
-	if err = rows.Err(); err != nil {
-		return "", err
-	}
-
+// resultsToJSON serializes rows returned by scanRows into indented JSON
+func resultsToJSON(results []map[string]any) (string, error) {
 	if len(results) == 0 {
 		return `{"results": []}`, nil
 	}
@@ -326,9 +509,9 @@ func executeSQLToJSON(db *sql.DB, query string) (string, error) {
 	return string(bytes), nil
 }
 
-// describeTable fetches detailed columns and types metadata securely from information_schema
-func describeTable(db *sql.DB, schemaName, tableName string) (string, error) {
-	rows, err := db.Query(`
+// describeTable fetches columns, primary key, indexes and foreign keys in both directions securely from information_schema
+func describeTable(ctx context.Context, db *sql.DB, schemaName, tableName string, policy pkg.Policy) (string, error) {
+	rows, err := db.QueryContext(ctx, `
 		SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_KEY, COLUMN_DEFAULT, EXTRA 
 		FROM information_schema.COLUMNS 
 		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
@@ -338,51 +521,52 @@ func describeTable(db *sql.DB, schemaName, tableName string) (string, error) {
 	}
 	defer rows.Close()
 
-	cols, err := rows.Columns()
+	results, err := scanRows(rows)
 	if err != nil {
 		return "", err
 	}
 
-	var results []map[string]any
-
-	currentRow := make([]any, len(cols))
-	for idx := range cols {
-		var i []byte
-		currentRow[idx] = &i
+	if len(results) == 0 {
+		return fmt.Sprintf("Table %q not found in schema %q.", tableName, schemaName), nil
 	}
 
-	for rows.Next() {
-		err := rows.Scan(currentRow...)
-		if err != nil {
-			return "", err
+	for _, column := range results {
+		name, _ := column["COLUMN_NAME"].(string)
+		if strategy := policy.MaskStrategy(tableName, name); strategy != "" {
+			column["MASKED"] = strategy
 		}
+	}
 
-		rowMap := make(map[string]any)
-		for idx, colname := range cols {
-			ptr := currentRow[idx]
-			bPtr := ptr.(*[]byte)
-			if bPtr == nil || *bPtr == nil {
-				rowMap[colname] = nil
-			} else {
-				rowMap[colname] = string(*bPtr)
-			}
-		}
-		results = append(results, rowMap)
+	indexes, err := tableIndexes(ctx, db, schemaName, tableName)
+	if err != nil {
+		return "", err
 	}
 
-	if err = rows.Err(); err != nil {
+	outgoing, err := foreignKeys(ctx, db, schemaName, tableName, true)
+	if err != nil {
 		return "", err
 	}
 
-	if len(results) == 0 {
-		return fmt.Sprintf("Table %q not found in schema %q.", tableName, schemaName), nil
+	incoming, err := foreignKeys(ctx, db, schemaName, tableName, false)
+	if err != nil {
+		return "", err
 	}
 
-	type tableColumns struct {
-		Columns []map[string]any `json:"columns"`
+	type tableDescription struct {
+		Columns      []map[string]any `json:"columns"`
+		PrimaryKey   []string         `json:"primary_key"`
+		Indexes      []tableIndex     `json:"indexes"`
+		ForeignKeys  []foreignKey     `json:"foreign_keys"`
+		ReferencedBy []foreignKey     `json:"referenced_by"`
 	}
 
-	bytes, err := json.MarshalIndent(tableColumns{Columns: results}, "", "  ")
+	bytes, err := json.MarshalIndent(tableDescription{
+		Columns:      results,
+		PrimaryKey:   primaryKey(indexes),
+		Indexes:      indexes,
+		ForeignKeys:  filterForeignKeys(policy, groupForeignKeys(outgoing)),
+		ReferencedBy: filterForeignKeys(policy, groupForeignKeys(incoming)),
+	}, "", "  ")
 	if err != nil {
 		return "", err
 	}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"reflect"
 	"testing"
 
 	"github.com/mark3labs/mcp-go/mcp"
@@ -55,3 +57,54 @@ func TestMcpServerInitialization(t *testing.T) {
 		return mcp.NewToolResultText("[]"), nil
 	})
 }
+
+func TestBindParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      any
+		expected []any
+		err      bool
+	}{
+		{
+			name:     "Missing params",
+			raw:      nil,
+			expected: nil,
+		},
+		{
+			name:     "Scalar types",
+			raw:      []any{"alice", float64(42), 3.5, true, nil},
+			expected: []any{"alice", int64(42), 3.5, true, nil},
+		},
+		{
+			name:     "Negative integral number",
+			raw:      []any{float64(-7)},
+			expected: []any{int64(-7)},
+		},
+		{
+			name:     "JSON numbers",
+			raw:      []any{json.Number("10"), json.Number("1.25")},
+			expected: []any{int64(10), 1.25},
+		},
+		{
+			name: "Not an array",
+			raw:  "alice",
+			err:  true,
+		},
+		{
+			name: "Nested object",
+			raw:  []any{map[string]any{"a": 1}},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := bindParams(tt.raw)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: bindParams() error = %v, expected err = %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: bindParams() = %#v, expected %#v", tt.name, got, tt.expected)
+		}
+	}
+}
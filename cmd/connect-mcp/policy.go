@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+var (
+	// tables following FROM, JOIN, UPDATE, INTO, TABLE or DESCRIBE, including comma separated lists
+	tableRefRe = regexp.MustCompile("(?i)\\b(?:from|join|update|into|table|describe|desc)(?:\\s+|\\s*\\(\\s*)((?:[`\"\\w$.]+\\s*(?:(?:as\\s+)?\\w+\\s*)?,\\s*)*[`\"\\w$.]+)")
+	aliasRe    = regexp.MustCompile(`(?i)\s+(?:as\s+)?\w+$`)
+	dotRe      = regexp.MustCompile(`\s*\.\s*`)
+	// identifiers, qualified or not, and single characters of a normalized query
+	queryTokenRe = regexp.MustCompile(`[\w$]+(?:\.[\w$*]+)*|\S`)
+	// catalogs listing every table and column, whatever the policy
+	systemSchemaRe = regexp.MustCompile(`(?i)\b(?:information_schema|performance_schema)\b|\b(?:mysql|sys)\.|\b(?:from|in|use|join)\s+(?:mysql|sys)\b`)
+	// SHOW statements listing table names outside of a Tables_in_* column
+	showTableListRe = regexp.MustCompile(`(?i)^\s*show\s+(?:table\s+status|open\s+tables)\b`)
+)
+
+// clauseKeywords start a clause of the statement, the identifiers are checked against the clause containing them
+var clauseKeywords = map[string]bool{
+	"SELECT": true, "FROM": true, "JOIN": true, "WHERE": true, "ON": true, "USING": true, "GROUP": true,
+	"ORDER": true, "BY": true, "HAVING": true, "LIMIT": true, "SET": true, "VALUES": true, "INTO": true,
+	"UPDATE": true, "TABLE": true, "RETURNING": true, "WINDOW": true,
+}
+
+// tableClauses list table names and their aliases
+var tableClauses = map[string]bool{"FROM": true, "JOIN": true, "INTO": true, "UPDATE": true, "TABLE": true}
+
+// normalizeQuery blanks string literals, removes comments and identifier quotes so that the
+// policy checks see the statement as MySQL runs it: the content of /*! */ comments is kept,
+// since MySQL executes it, and -- starts a comment only when followed by a space
+func normalizeQuery(query string) string {
+	var out strings.Builder
+	executable := false
+	for i := 0; i < len(query); {
+		rest := query[i:]
+		switch {
+		case rest[0] == '\'':
+			i = closeQuoted(query, i)
+			out.WriteString("''")
+		case rest[0] == '"' || rest[0] == '`':
+			end := closeQuoted(query, i)
+			out.WriteString(strings.NewReplacer("'", "", "\"", "", "`", "").Replace(query[i+1 : end]))
+			i = end
+		case rest[0] == '#' || strings.HasPrefix(rest, "--") && (len(rest) == 2 || unicode.IsSpace(rune(rest[2]))):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			out.WriteByte(' ')
+			i += end
+		case strings.HasPrefix(rest, "/*!"):
+			i += 3
+			for i < len(query) && unicode.IsDigit(rune(query[i])) {
+				i += 1
+			}
+			executable = true
+			out.WriteByte(' ')
+		case strings.HasPrefix(rest, "*/") && executable:
+			executable = false
+			out.WriteByte(' ')
+			i += 2
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest[2:], "*/")
+			if end < 0 {
+				end = len(rest) - 4
+			}
+			out.WriteByte(' ')
+			i += end + 4
+		default:
+			out.WriteByte(rest[0])
+			i += 1
+		}
+	}
+	return dotRe.ReplaceAllString(out.String(), ".")
+}
+
+// closeQuoted returns the position after the quote closing the one at start, doubled quotes and
+// backslashes, outside of backticks, escape it
+func closeQuoted(query string, start int) int {
+	quote := query[start]
+	for i := start + 1; i < len(query); i++ {
+		switch {
+		case query[i] == '\\' && quote != '`':
+			i += 1
+		case query[i] == quote && i+1 < len(query) && query[i+1] == quote:
+			i += 1
+		case query[i] == quote:
+			return i + 1
+		}
+	}
+	return len(query)
+}
+
+// unquoteTable strips backticks/double quotes and the schema prefix from a table reference
+func unquoteTable(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if idx := strings.LastIndex(ref, "."); idx >= 0 {
+		ref = ref[idx+1:]
+	}
+	return strings.Trim(ref, "`\"")
+}
+
+// referencedTables returns the tables a query reads or writes, best-effort and without a full SQL parser
+func referencedTables(query string) []string {
+	query = normalizeQuery(query)
+
+	seen := map[string]bool{}
+	tables := []string{}
+	for _, m := range tableRefRe.FindAllStringSubmatch(query, -1) {
+		for _, ref := range strings.Split(m[1], ",") {
+			ref = aliasRe.ReplaceAllString(strings.TrimSpace(ref), "")
+			table := unquoteTable(ref)
+			// FROM (SELECT ...) is a subquery, its own FROM is matched on its own
+			if table == "" || seen[table] || strings.EqualFold(table, "select") {
+				continue
+			}
+			seen[table] = true
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// checkQuery rejects before execution a query the policy cannot be enforced on: one reading the
+// system catalogs, referencing a hidden table or using a masked column other than as is
+func checkQuery(policy pkg.Policy, query string) error {
+	if !policy.Restricted() {
+		return nil
+	}
+
+	normalized := normalizeQuery(query)
+	if systemSchemaRe.MatchString(normalized) {
+		return fmt.Errorf("access to the system schemas is denied by policy")
+	}
+	if showTableListRe.MatchString(normalized) {
+		return fmt.Errorf("SHOW TABLE STATUS and SHOW OPEN TABLES are denied by policy, use SHOW TABLES")
+	}
+	if err := checkTables(policy, referencedTables(normalized)); err != nil {
+		return err
+	}
+	return checkMaskedColumns(policy, normalized)
+}
+
+// checkMaskedColumns rejects the normalized query if it uses a masked column other than as a plain
+// column of the outermost select list. Results are masked by column name, so aliases, expressions,
+// subqueries and unions would return the values unmasked, and conditions would reveal them.
+func checkMaskedColumns(policy pkg.Policy, query string) error {
+	tokens := queryTokenRe.FindAllString(query, -1)
+	compound := false
+	for _, token := range tokens {
+		switch strings.ToUpper(token) {
+		case "UNION", "INTERSECT", "EXCEPT":
+			compound = true
+		}
+	}
+
+	// the clause of each level of parentheses
+	clauses := []string{""}
+	for i, token := range tokens {
+		upper := strings.ToUpper(token)
+		switch {
+		case token == "(":
+			clauses = append(clauses, "")
+			continue
+		case token == ")":
+			if len(clauses) > 1 {
+				clauses = clauses[:len(clauses)-1]
+			}
+			continue
+		case clauseKeywords[upper]:
+			clauses[len(clauses)-1] = upper
+			continue
+		}
+
+		clause := clauses[len(clauses)-1]
+		first := rune(token[0])
+		if !unicode.IsLetter(first) && first != '_' && first != '$' || tableClauses[clause] {
+			continue
+		}
+		column := token[strings.LastIndex(token, ".")+1:]
+		if policy.MaskStrategyAnyTable(column) == "" {
+			continue
+		}
+
+		prev, next := "", ""
+		if i > 0 {
+			prev = strings.ToUpper(tokens[i-1])
+		}
+		if i+1 < len(tokens) {
+			next = strings.ToUpper(tokens[i+1])
+		}
+		plain := len(clauses) == 1 && clause == "SELECT" && !compound &&
+			slices.Contains([]string{"SELECT", "DISTINCT", "ALL", ","}, prev) &&
+			slices.Contains([]string{"", ",", "FROM", ";"}, next)
+		if !plain {
+			return fmt.Errorf("column %q is masked by policy and can only be selected as is", column)
+		}
+	}
+	return nil
+}
+
+// checkTables rejects the query before execution if it references a table hidden by the policy
+func checkTables(policy pkg.Policy, tables []string) error {
+	for _, table := range tables {
+		if !policy.AllowsTable(table) {
+			return fmt.Errorf("access to table %q is denied by policy", table)
+		}
+	}
+	return nil
+}
+
+// maskRow replaces in place the values of masked columns of the tables. Without tables the row comes
+// from an arbitrary query and its originating table is unknown, so the rules match any table.
+func maskRow(policy pkg.Policy, tables []string, row map[string]any) {
+	for column, value := range row {
+		strategy := policy.MaskStrategy("", column)
+		if tables == nil {
+			strategy = policy.MaskStrategyAnyTable(column)
+		}
+		for _, table := range tables {
+			if strategy != "" {
+				break
+			}
+			strategy = policy.MaskStrategy(table, column)
+		}
+
+		if s, ok := value.(string); ok && strategy != "" {
+			row[column] = pkg.MaskValue(strategy, s)
+		}
+	}
+}
+
+// applyPolicy hides the rows of SHOW TABLES listing denied tables and masks the remaining rows
+func applyPolicy(policy pkg.Policy, tables []string, rows []map[string]any) []map[string]any {
+	filtered := []map[string]any{}
+	for _, row := range rows {
+		if hidesTable(policy, row) {
+			continue
+		}
+		maskRow(policy, tables, row)
+		filtered = append(filtered, row)
+	}
+	return filtered
+}
+
+// hidesTable reports whether the row is a SHOW TABLES entry for a table denied by the policy
+func hidesTable(policy pkg.Policy, row map[string]any) bool {
+	for column, value := range row {
+		if !strings.HasPrefix(column, "Tables_in_") {
+			continue
+		}
+		if table, ok := value.(string); ok && !policy.AllowsTable(table) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPolicyJSON applies the policy to the rows of a {"results": [...]} payload, leaving any other payload untouched
+func applyPolicyJSON(policy pkg.Policy, tables []string, payload string) (string, error) {
+	var parsed struct {
+		Results []map[string]any `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(payload), &parsed); err != nil || len(parsed.Results) == 0 {
+		return payload, nil
+	}
+
+	return resultsToJSON(applyPolicy(policy, tables, parsed.Results))
+}
+
+// filterForeignKeys drops the relationships involving a table hidden by the policy
+func filterForeignKeys(policy pkg.Policy, keys []foreignKey) []foreignKey {
+	filtered := []foreignKey{}
+	for _, key := range keys {
+		if policy.AllowsTable(key.Table) && policy.AllowsTable(key.ReferencedTable) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestReferencedTables(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"SELECT * FROM users", []string{"users"}},
+		{"select * from `shop`.`users` u join orders o on o.user_id = u.id", []string{"users", "orders"}},
+		{"select * from users u, orders as o where u.id = o.user_id", []string{"users", "orders"}},
+		{"select * from users where id in (select user_id from users_passwords)", []string{"users", "users_passwords"}},
+		{"select 'from secrets' from users", []string{"users"}},
+		{"UPDATE accounts SET balance = 0", []string{"accounts"}},
+		{"insert into logs (msg) values ('x')", []string{"logs"}},
+		{"describe users_passwords", []string{"users_passwords"}},
+		{"select date_from from events", []string{"events"}},
+		{"select 1", []string{}},
+		{"select * from/**/users_passwords", []string{"users_passwords"}},
+		{"select * from -- comment\n users_passwords", []string{"users_passwords"}},
+		{"select * from \"users_passwords\"", []string{"users_passwords"}},
+		{"select * from shop . `users_passwords`", []string{"users_passwords"}},
+		{"select * from (users_passwords)", []string{"users_passwords"}},
+		{"select * from (select * from users_passwords) p", []string{"users_passwords"}},
+		{"select 1 /*!50000 from users_passwords */", []string{"users_passwords"}},
+	}
+
+	for _, tt := range tests {
+		got := referencedTables(tt.query)
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("referencedTables(%q) = %q; expected %q", tt.query, got, tt.expected)
+		}
+	}
+}
+
+func TestApplyPolicy(t *testing.T) {
+	policy := pkg.Policy{
+		DenyTables: []string{"users_passwords"},
+		Mask:       []pkg.MaskRule{{Column: "users.email", Strategy: pkg.MaskRedact}},
+	}
+
+	rows := []map[string]any{
+		{"Tables_in_shop": "users"},
+		{"Tables_in_shop": "users_passwords"},
+	}
+	got := applyPolicy(policy, nil, rows)
+	if len(got) != 1 || got[0]["Tables_in_shop"] != "users" {
+		t.Errorf("expected denied table to be hidden, got %v", got)
+	}
+
+	rows = []map[string]any{
+		{"id": "1", "email": "alice@example.com", "manager": nil},
+	}
+	got = applyPolicy(policy, []string{"users"}, rows)
+	if got[0]["email"] == "alice@example.com" || got[0]["id"] != "1" || got[0]["manager"] != nil {
+		t.Errorf("expected only email to be masked, got %v", got)
+	}
+
+	// the table of an arbitrary query is unknown, e.g. a view over users
+	rows = []map[string]any{{"id": "1", "email": "alice@example.com"}}
+	got = applyPolicy(policy, nil, rows)
+	if got[0]["email"] == "alice@example.com" {
+		t.Errorf("expected email to be masked in any table, got %v", got)
+	}
+}
+
+func TestCheckTables(t *testing.T) {
+	policy := pkg.Policy{DenyTables: []string{"*_passwords"}}
+
+	if err := checkTables(policy, []string{"users"}); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if err := checkTables(policy, []string{"users", "users_passwords"}); err == nil {
+		t.Error("expected denied table to be rejected")
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	policy := pkg.Policy{
+		DenyTables: []string{"users_passwords"},
+		Mask:       []pkg.MaskRule{{Column: "users.email", Strategy: pkg.MaskRedact}},
+	}
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{"SELECT id, email FROM users", true},
+		{"select u.email, name from users u where id = 1;", true},
+		{"SELECT * FROM users", true},
+		{"SELECT DISTINCT `email` FROM users", true},
+		{"SELECT email AS e FROM users", false},
+		{"SELECT email e FROM users", false},
+		{"SELECT CONCAT(email) FROM users", false},
+		{"SELECT email || '' FROM users", false},
+		{"SELECT id FROM users WHERE email LIKE 'a%'", false},
+		{"SELECT id FROM users ORDER BY email", false},
+		{"SELECT (SELECT email FROM users LIMIT 1) AS x", false},
+		{"SELECT name FROM orders UNION SELECT email FROM users", false},
+		{"SELECT email/**/AS/**/e FROM users", false},
+		{"SELECT email -- \nAS e FROM users", false},
+		{"SELECT email --1 AS e FROM users", false},
+		{"SELECT id FROM users /* email */", true},
+		{"SELECT 'email AS e' FROM users", true},
+		{"SELECT * FROM/**/users_passwords", false},
+		{"SELECT * FROM `users_passwords`", false},
+		{"SELECT table_name FROM information_schema.tables", false},
+		{"SELECT * FROM `INFORMATION_SCHEMA`.`COLUMNS`", false},
+		{"SELECT user FROM mysql.user", false},
+		{"SHOW TABLES FROM mysql", false},
+		{"SHOW TABLES", true},
+		{"SHOW TABLE STATUS", false},
+		{"show table  status like 'users%'", false},
+		{"/* x */ SHOW OPEN TABLES", false},
+	}
+
+	for _, tt := range tests {
+		err := checkQuery(policy, tt.query)
+		if (err == nil) != tt.expected {
+			t.Errorf("checkQuery(%q) = %v; expected allowed %v", tt.query, err, tt.expected)
+		}
+	}
+
+	if err := checkQuery(pkg.Policy{}, "SELECT table_name FROM information_schema.tables"); err != nil {
+		t.Errorf("expected no checks without a policy, got %v", err)
+	}
+}
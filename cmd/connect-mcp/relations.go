@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+type tableIndex struct {
+	Name    string   `json:"name"`
+	Unique  bool     `json:"unique"`
+	Columns []string `json:"columns"`
+}
+
+type foreignKey struct {
+	Name              string   `json:"constraint"`
+	Table             string   `json:"table"`
+	Columns           []string `json:"columns"`
+	ReferencedSchema  string   `json:"referenced_schema"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+}
+
+// indexColumn is a single row of information_schema.STATISTICS
+type indexColumn struct {
+	Index     string
+	NonUnique bool
+	Column    string
+}
+
+// fkColumn is a single row of information_schema.KEY_COLUMN_USAGE describing one column of a foreign key
+type fkColumn struct {
+	Constraint       string
+	Table            string
+	Column           string
+	ReferencedSchema string
+	ReferencedTable  string
+	ReferencedColumn string
+}
+
+// groupIndexes merges per-column rows (ordered by index and sequence) into one entry per index
+func groupIndexes(columns []indexColumn) []tableIndex {
+	indexes := []tableIndex{}
+	for _, c := range columns {
+		last := len(indexes) - 1
+		if last < 0 || indexes[last].Name != c.Index {
+			indexes = append(indexes, tableIndex{Name: c.Index, Unique: !c.NonUnique})
+			last++
+		}
+		indexes[last].Columns = append(indexes[last].Columns, c.Column)
+	}
+	return indexes
+}
+
+// groupForeignKeys merges per-column rows (ordered by table, constraint and position) into one entry per constraint
+func groupForeignKeys(columns []fkColumn) []foreignKey {
+	keys := []foreignKey{}
+	for _, c := range columns {
+		last := len(keys) - 1
+		if last < 0 || keys[last].Name != c.Constraint || keys[last].Table != c.Table {
+			keys = append(keys, foreignKey{
+				Name:             c.Constraint,
+				Table:            c.Table,
+				ReferencedSchema: c.ReferencedSchema,
+				ReferencedTable:  c.ReferencedTable,
+			})
+			last++
+		}
+		keys[last].Columns = append(keys[last].Columns, c.Column)
+		keys[last].ReferencedColumns = append(keys[last].ReferencedColumns, c.ReferencedColumn)
+	}
+	return keys
+}
+
+// primaryKey returns the columns of the PRIMARY index, if any
+func primaryKey(indexes []tableIndex) []string {
+	for _, idx := range indexes {
+		if idx.Name == "PRIMARY" {
+			return idx.Columns
+		}
+	}
+	return []string{}
+}
+
+// tableIndexes reads the indexes of a table from information_schema.STATISTICS
+func tableIndexes(ctx context.Context, db *sql.DB, schemaName, tableName string) ([]tableIndex, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT INDEX_NAME, NON_UNIQUE, COLUMN_NAME
+		FROM information_schema.STATISTICS
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+		ORDER BY INDEX_NAME = 'PRIMARY' DESC, INDEX_NAME, SEQ_IN_INDEX`, schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []indexColumn
+	for rows.Next() {
+		var c indexColumn
+		var column sql.NullString
+		if err := rows.Scan(&c.Index, &c.NonUnique, &column); err != nil {
+			return nil, err
+		}
+		// functional key parts have no column name
+		c.Column = column.String
+		columns = append(columns, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groupIndexes(columns), nil
+}
+
+// foreignKeys reads foreign keys from information_schema.KEY_COLUMN_USAGE.
+// When outgoing is true it returns the keys declared on tableName, otherwise the keys of other tables referencing it.
+// An empty tableName returns every foreign key of the schema.
+func foreignKeys(ctx context.Context, db *sql.DB, schemaName, tableName string, outgoing bool) ([]fkColumn, error) {
+	query := `
+		SELECT CONSTRAINT_NAME, TABLE_NAME, COLUMN_NAME, REFERENCED_TABLE_SCHEMA, REFERENCED_TABLE_NAME, REFERENCED_COLUMN_NAME
+		FROM information_schema.KEY_COLUMN_USAGE
+		WHERE REFERENCED_TABLE_NAME IS NOT NULL`
+	args := []any{}
+
+	switch {
+	case tableName == "":
+		query += " AND TABLE_SCHEMA = ?"
+		args = append(args, schemaName)
+	case outgoing:
+		query += " AND TABLE_SCHEMA = ? AND TABLE_NAME = ?"
+		args = append(args, schemaName, tableName)
+	default:
+		query += " AND REFERENCED_TABLE_SCHEMA = ? AND REFERENCED_TABLE_NAME = ?"
+		args = append(args, schemaName, tableName)
+	}
+	query += " ORDER BY TABLE_NAME, CONSTRAINT_NAME, ORDINAL_POSITION"
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []fkColumn
+	for rows.Next() {
+		var c fkColumn
+		err := rows.Scan(&c.Constraint, &c.Table, &c.Column, &c.ReferencedSchema, &c.ReferencedTable, &c.ReferencedColumn)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// listRelationships returns the foreign keys of the schema, or both directions of a single table when tableName is set
+func listRelationships(ctx context.Context, db *sql.DB, schemaName, tableName string, policy pkg.Policy) (string, error) {
+	outgoing, err := foreignKeys(ctx, db, schemaName, tableName, true)
+	if err != nil {
+		return "", err
+	}
+
+	var result any
+	if tableName == "" {
+		result = struct {
+			Relationships []foreignKey `json:"relationships"`
+		}{filterForeignKeys(policy, groupForeignKeys(outgoing))}
+	} else {
+		incoming, err := foreignKeys(ctx, db, schemaName, tableName, false)
+		if err != nil {
+			return "", err
+		}
+		result = struct {
+			References   []foreignKey `json:"references"`
+			ReferencedBy []foreignKey `json:"referenced_by"`
+		}{filterForeignKeys(policy, groupForeignKeys(outgoing)), filterForeignKeys(policy, groupForeignKeys(incoming))}
+	}
+
+	bytes, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
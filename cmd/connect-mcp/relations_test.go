@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupIndexes(t *testing.T) {
+	columns := []indexColumn{
+		{Index: "PRIMARY", NonUnique: false, Column: "id"},
+		{Index: "idx_name", NonUnique: true, Column: "last_name"},
+		{Index: "idx_name", NonUnique: true, Column: "first_name"},
+		{Index: "uq_email", NonUnique: false, Column: "email"},
+	}
+
+	expected := []tableIndex{
+		{Name: "PRIMARY", Unique: true, Columns: []string{"id"}},
+		{Name: "idx_name", Unique: false, Columns: []string{"last_name", "first_name"}},
+		{Name: "uq_email", Unique: true, Columns: []string{"email"}},
+	}
+
+	got := groupIndexes(columns)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("groupIndexes() = %+v; expected %+v", got, expected)
+	}
+
+	pk := primaryKey(got)
+	if !reflect.DeepEqual(pk, []string{"id"}) {
+		t.Errorf("primaryKey() = %v; expected [id]", pk)
+	}
+}
+
+func TestPrimaryKeyMissing(t *testing.T) {
+	got := primaryKey(groupIndexes(nil))
+	if got == nil || len(got) != 0 {
+		t.Errorf("primaryKey() = %#v; expected empty slice", got)
+	}
+}
+
+func TestGroupForeignKeys(t *testing.T) {
+	columns := []fkColumn{
+		{Constraint: "fk_order", Table: "order_items", Column: "order_id", ReferencedSchema: "shop", ReferencedTable: "orders", ReferencedColumn: "id"},
+		{Constraint: "fk_product", Table: "order_items", Column: "product_id", ReferencedSchema: "shop", ReferencedTable: "products", ReferencedColumn: "id"},
+		{Constraint: "fk_product", Table: "order_items", Column: "product_variant", ReferencedSchema: "shop", ReferencedTable: "products", ReferencedColumn: "variant"},
+		{Constraint: "fk_order", Table: "shipments", Column: "order_id", ReferencedSchema: "shop", ReferencedTable: "orders", ReferencedColumn: "id"},
+	}
+
+	expected := []foreignKey{
+		{Name: "fk_order", Table: "order_items", Columns: []string{"order_id"}, ReferencedSchema: "shop", ReferencedTable: "orders", ReferencedColumns: []string{"id"}},
+		{Name: "fk_product", Table: "order_items", Columns: []string{"product_id", "product_variant"}, ReferencedSchema: "shop", ReferencedTable: "products", ReferencedColumns: []string{"id", "variant"}},
+		{Name: "fk_order", Table: "shipments", Columns: []string{"order_id"}, ReferencedSchema: "shop", ReferencedTable: "orders", ReferencedColumns: []string{"id"}},
+	}
+
+	got := groupForeignKeys(columns)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("groupForeignKeys() = %+v; expected %+v", got, expected)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+const (
+	defaultSampleRows = 10
+	maxSampleRows     = 100
+	defaultTopValues  = 5
+)
+
+// quoteIdentifier wraps a table or column name in backticks, doubling any backtick it contains
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// sampleLimit clamps the requested number of rows between 1 and the configured row limit
+func sampleLimit(n, rowLimit int) int {
+	limit := maxSampleRows
+	if rowLimit > 0 && rowLimit < limit {
+		limit = rowLimit
+	}
+
+	if n <= 0 {
+		n = defaultSampleRows
+	}
+	return min(n, limit)
+}
+
+// scanRows reads every row of the result set into a map keyed by column name
+func scanRows(rows *sql.Rows) ([]map[string]any, error) {
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string]any
+
+	currentRow := make([]any, len(cols))
+	for idx := range cols {
+		var i []byte
+		currentRow[idx] = &i
+	}
+
+	for rows.Next() {
+		err := rows.Scan(currentRow...)
+		if err != nil {
+			return nil, err
+		}
+
+		rowMap := make(map[string]any)
+		for idx, colname := range cols {
+			bPtr := currentRow[idx].(*[]byte)
+			if bPtr == nil || *bPtr == nil {
+				rowMap[colname] = nil
+			} else {
+				rowMap[colname] = string(*bPtr)
+			}
+		}
+		results = append(results, rowMap)
+	}
+
+	return results, rows.Err()
+}
+
+// readOnlyQuery runs a query inside a read-only transaction, so that inspection tools can never modify data
+func readOnlyQuery(ctx context.Context, db *sql.DB, query string, args ...any) ([]map[string]any, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRows(rows)
+}
+
+// tableExists checks information_schema so that only known table names are interpolated in queries
+func tableExists(ctx context.Context, db *sql.DB, schemaName, tableName string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM information_schema.TABLES
+		WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?`, schemaName, tableName).Scan(&count)
+	return count > 0, err
+}
+
+// sampleRows returns up to n rows of the table, clamped by the configured row limit
+func sampleRows(ctx context.Context, db *sql.DB, schemaName, tableName string, n, rowLimit int, policy pkg.Policy) (string, error) {
+	ok, err := tableExists(ctx, db, schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return fmt.Sprintf("Table %q not found in schema %q.", tableName, schemaName), nil
+	}
+
+	limit := sampleLimit(n, rowLimit)
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT %d", quoteIdentifier(tableName), limit)
+
+	results, err := readOnlyQuery(ctx, db, query)
+	if err != nil {
+		return "", err
+	}
+	for _, row := range results {
+		maskRow(policy, []string{tableName}, row)
+	}
+	if results == nil {
+		results = []map[string]any{}
+	}
+
+	type sampleResult struct {
+		Table   string           `json:"table"`
+		Limit   int              `json:"limit"`
+		Results []map[string]any `json:"results"`
+	}
+
+	bytes, err := json.MarshalIndent(sampleResult{Table: tableName, Limit: limit, Results: results}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+// parseCount converts a COUNT(*) value returned by scanRows into an integer
+func parseCount(value any) int64 {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	count, _ := strconv.ParseInt(s, 10, 64)
+	return count
+}
+
+// maskAny masks a value returned by scanRows, leaving NULLs untouched
+func maskAny(strategy string, value any) any {
+	if s, ok := value.(string); ok {
+		return pkg.MaskValue(strategy, s)
+	}
+	return value
+}
+
+type valueCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+type columnStats struct {
+	Table         string       `json:"table"`
+	Column        string       `json:"column"`
+	DataType      string       `json:"data_type"`
+	Nullable      bool         `json:"nullable"`
+	EstimatedRows *int64       `json:"estimated_rows"`
+	TotalRows     int64        `json:"total_rows"`
+	NullCount     int64        `json:"null_count"`
+	NullRatio     float64      `json:"null_ratio"`
+	DistinctCount int64        `json:"distinct_count"`
+	Min           any          `json:"min"`
+	Max           any          `json:"max"`
+	TopValues     []valueCount `json:"top_values"`
+}
+
+// getColumnStats computes distinct count, null ratio, min/max and the most frequent values of a column
+func getColumnStats(ctx context.Context, db *sql.DB, schemaName, tableName, columnName string, top, rowLimit int, policy pkg.Policy) (string, error) {
+	stats := columnStats{
+		Table:     tableName,
+		Column:    columnName,
+		TopValues: []valueCount{},
+	}
+
+	var nullable string
+	err := db.QueryRowContext(ctx, `
+		SELECT c.DATA_TYPE, c.IS_NULLABLE, t.TABLE_ROWS
+		FROM information_schema.COLUMNS c
+		JOIN information_schema.TABLES t
+			ON t.TABLE_SCHEMA = c.TABLE_SCHEMA AND t.TABLE_NAME = c.TABLE_NAME
+		WHERE c.TABLE_SCHEMA = ? AND c.TABLE_NAME = ? AND c.COLUMN_NAME = ?`,
+		schemaName, tableName, columnName,
+	).Scan(&stats.DataType, &nullable, &stats.EstimatedRows)
+	if err == sql.ErrNoRows {
+		return fmt.Sprintf("Column %q not found in table %q of schema %q.", columnName, tableName, schemaName), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	stats.Nullable = nullable == "YES"
+
+	table := quoteIdentifier(tableName)
+	column := quoteIdentifier(columnName)
+
+	aggregates, err := readOnlyQuery(ctx, db, fmt.Sprintf(
+		"SELECT COUNT(*) AS total, COUNT(%[1]s) AS non_null, COUNT(DISTINCT %[1]s) AS distinct_count, MIN(%[1]s) AS min_value, MAX(%[1]s) AS max_value FROM %[2]s",
+		column, table,
+	))
+	if err != nil {
+		return "", err
+	}
+	if len(aggregates) == 1 {
+		row := aggregates[0]
+		stats.TotalRows = parseCount(row["total"])
+		stats.DistinctCount = parseCount(row["distinct_count"])
+		stats.Min = row["min_value"]
+		stats.Max = row["max_value"]
+
+		stats.NullCount = stats.TotalRows - parseCount(row["non_null"])
+		if stats.TotalRows > 0 {
+			stats.NullRatio = float64(stats.NullCount) / float64(stats.TotalRows)
+		}
+	}
+
+	if top <= 0 {
+		top = defaultTopValues
+	}
+	top = sampleLimit(top, rowLimit)
+
+	topValues, err := readOnlyQuery(ctx, db, fmt.Sprintf(
+		"SELECT %[1]s AS value, COUNT(*) AS frequency FROM %[2]s GROUP BY %[1]s ORDER BY frequency DESC LIMIT %[3]d",
+		column, table, top,
+	))
+	if err != nil {
+		return "", err
+	}
+	for _, row := range topValues {
+		stats.TopValues = append(stats.TopValues, valueCount{
+			Value: row["value"],
+			Count: parseCount(row["frequency"]),
+		})
+	}
+
+	if strategy := policy.MaskStrategy(tableName, columnName); strategy != "" {
+		stats.Min = maskAny(strategy, stats.Min)
+		stats.Max = maskAny(strategy, stats.Max)
+		for i := range stats.TopValues {
+			stats.TopValues[i].Value = maskAny(strategy, stats.TopValues[i].Value)
+		}
+	}
+
+	bytes, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"users", "`users`"},
+		{"order items", "`order items`"},
+		{"weird`name", "`weird``name`"},
+	}
+
+	for _, tt := range tests {
+		got := quoteIdentifier(tt.name)
+		if got != tt.expected {
+			t.Errorf("quoteIdentifier(%q) = %q; expected %q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+func TestSampleLimit(t *testing.T) {
+	tests := []struct {
+		n        int
+		rowLimit int
+		expected int
+	}{
+		{n: 0, rowLimit: 0, expected: defaultSampleRows},
+		{n: -5, rowLimit: 0, expected: defaultSampleRows},
+		{n: 20, rowLimit: 0, expected: 20},
+		{n: 20, rowLimit: 5, expected: 5},
+		{n: 1000, rowLimit: 0, expected: maxSampleRows},
+		{n: 1000, rowLimit: 5000, expected: maxSampleRows},
+	}
+
+	for _, tt := range tests {
+		got := sampleLimit(tt.n, tt.rowLimit)
+		if got != tt.expected {
+			t.Errorf("sampleLimit(%d, %d) = %d; expected %d", tt.n, tt.rowLimit, got, tt.expected)
+		}
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected int64
+	}{
+		{"42", 42},
+		{"0", 0},
+		{nil, 0},
+		{"not a number", 0},
+	}
+
+	for _, tt := range tests {
+		got := parseCount(tt.value)
+		if got != tt.expected {
+			t.Errorf("parseCount(%v) = %d; expected %d", tt.value, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"database/sql"
+	"log/slog"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+// tableKeywords are followed by a table name
+var tableKeywords = []string{"FROM", "JOIN", "INTO", "UPDATE", "TABLE", "DESCRIBE"}
+
+var (
+	tableReference = regexp.MustCompile(`(?i)\b(?:from|join|into|update|table|describe)\s+([\w.$]+)(?:\s+(?:as\s+)?(\w+))?`)
+	ddlStatement   = regexp.MustCompile(`(?i)^\s*(create|alter|drop|rename|truncate)\b`)
+)
+
+// configOptions are the names accepted by \config get and set
+var configOptions = []string{"autolimit", "histsize", "tabsize"}
+
+// sqlCompleter completes slash commands, \config options, SQL keywords, tables and their columns.
+// The schema is read on the first completion needing it and kept until a DDL statement runs.
+type sqlCompleter struct {
+	db     *sql.DB
+	driver string
+	schema map[string][]string
+}
+
+// Complete implements terminal.Completer
+func (c *sqlCompleter) Complete(line string, pos int) (string, []string) {
+	runes := []rune(line)
+	end := min(pos, len(runes))
+	start := end
+	for start > 0 && (isWordRune(runes[start-1]) || runes[start-1] == '\\') {
+		start -= 1
+	}
+	before, word := string(runes[:start]), string(runes[start:end])
+
+	if IsCommand(line) {
+		return word, completeCommand(tokenize(before), word)
+	}
+
+	// table.column completes the columns of the table, or of the table with that alias
+	if qualifier, prefix, ok := strings.Cut(word, "."); ok {
+		table := qualifier
+		if name, ok := statementTables(line)[strings.ToLower(qualifier)]; ok {
+			table = name
+		}
+		candidates := []string{}
+		for _, column := range matching(c.columns(table), prefix) {
+			candidates = append(candidates, qualifier+"."+column)
+		}
+		return word, candidates
+	}
+
+	if tableContext(before) {
+		names := []string{}
+		for table := range c.tables() {
+			names = append(names, table)
+		}
+		return word, matching(names, word)
+	}
+
+	// an empty word is left to the terminal, which indents with a tab
+	if word == "" {
+		return word, nil
+	}
+
+	candidates := []string{}
+	for _, table := range statementTables(line) {
+		for _, column := range matching(c.columns(table), word) {
+			if !slices.Contains(candidates, column) {
+				candidates = append(candidates, column)
+			}
+		}
+	}
+	sort.Strings(candidates)
+
+	// keywords keep the typed case, the rest follows the case of the last typed letter
+	typed := []rune(word)
+	for _, keyword := range matching(terminal.SQLKeywords, word) {
+		rest := string([]rune(keyword)[len(typed):])
+		if unicode.IsLower(typed[len(typed)-1]) {
+			rest = strings.ToLower(rest)
+		}
+		candidates = append(candidates, word+rest)
+	}
+	return word, candidates
+}
+
+// Invalidate drops the cached schema, read again on the next completion
+func (c *sqlCompleter) Invalidate() {
+	c.schema = nil
+}
+
+func (c *sqlCompleter) tables() map[string][]string {
+	if c.schema == nil {
+		schema, err := loadSchema(c.db, c.driver)
+		if err != nil {
+			slog.Debug("Failed to read the schema for completion", "err", err)
+		}
+		c.schema = schema
+	}
+	return c.schema
+}
+
+// columns of the table, its name is matched ignoring case when not found as is
+func (c *sqlCompleter) columns(table string) []string {
+	tables := c.tables()
+	if columns, ok := tables[table]; ok {
+		return columns
+	}
+	for name, columns := range tables {
+		if strings.EqualFold(name, table) {
+			return columns
+		}
+	}
+	return nil
+}
+
+// completeCommand completes the slash command names, and the arguments of \config
+func completeCommand(tokens []string, word string) []string {
+	switch {
+	case len(tokens) == 0:
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		return matching(names, word)
+	case tokens[0] != "\\config":
+		return nil
+	case len(tokens) == 1:
+		return matching([]string{"get", "set", "reset"}, word)
+	case len(tokens) == 2 && (tokens[1] == "get" || tokens[1] == "set"):
+		return matching(configOptions, word)
+	}
+	return nil
+}
+
+// loadSchema reads the columns of every table of the current database
+func loadSchema(db *sql.DB, driver string) (map[string][]string, error) {
+	query := `
+		SELECT TABLE_NAME, COLUMN_NAME FROM information_schema.COLUMNS
+		WHERE TABLE_SCHEMA = DATABASE()
+		ORDER BY TABLE_NAME, ORDINAL_POSITION`
+	switch driver {
+	case pkg.DriverSQLite:
+		query = `
+			SELECT m.name, p.name FROM sqlite_master m
+			JOIN pragma_table_info(m.name) p
+			WHERE m.type IN ('table', 'view')
+			ORDER BY m.name, p.cid`
+	case pkg.DriverPostgres:
+		query = `
+			SELECT table_name, column_name FROM information_schema.columns
+			WHERE table_schema = current_schema()
+			ORDER BY table_name, ordinal_position`
+	}
+
+	schema := map[string][]string{}
+	rows, err := db.Query(query)
+	if err != nil {
+		return schema, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return schema, err
+		}
+		schema[table] = append(schema[table], column)
+	}
+	return schema, rows.Err()
+}
+
+// statementTables maps the tables referenced by the statement, and their aliases, to the table name
+func statementTables(line string) map[string]string {
+	tables := map[string]string{}
+	for _, match := range tableReference.FindAllStringSubmatch(line, -1) {
+		table := match[1]
+		if _, name, ok := strings.Cut(table, "."); ok {
+			table = name
+		}
+		tables[strings.ToLower(table)] = table
+
+		alias := match[2]
+		if alias != "" && !slices.Contains(terminal.SQLKeywords, strings.ToUpper(alias)) {
+			tables[strings.ToLower(alias)] = table
+		}
+	}
+	return tables
+}
+
+// tableContext reports whether a table name is expected after the text, that is after one of
+// tableKeywords or after a comma in the list of tables of FROM
+func tableContext(text string) bool {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !isWordRune(r) })
+	if len(words) == 0 {
+		return false
+	}
+	if !strings.HasSuffix(strings.TrimRightFunc(text, unicode.IsSpace), ",") {
+		return slices.Contains(tableKeywords, strings.ToUpper(words[len(words)-1]))
+	}
+
+	for i := len(words) - 1; i >= 0; i-- {
+		if slices.Contains(terminal.SQLKeywords, strings.ToUpper(words[i])) {
+			return strings.EqualFold(words[i], "FROM")
+		}
+	}
+	return false
+}
+
+// matching returns the sorted words starting with prefix, ignoring case
+func matching(words []string, prefix string) []string {
+	result := []string{}
+	for _, word := range words {
+		if strings.HasPrefix(strings.ToLower(word), strings.ToLower(prefix)) {
+			result = append(result, word)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '$'
+}
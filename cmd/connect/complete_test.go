@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestComplete(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range []string{
+		"CREATE TABLE users (id INTEGER, name TEXT, email TEXT)",
+		"CREATE TABLE orders (id INTEGER, user_id INTEGER, total REAL)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	completer := &sqlCompleter{db: db, driver: pkg.DriverSQLite}
+
+	tests := []struct {
+		line       string
+		word       string
+		candidates []string
+	}{
+		{line: "\\con", word: "\\con", candidates: []string{"\\config"}},
+		{line: "\\config s", word: "s", candidates: []string{"set"}},
+		{line: "\\config set h", word: "h", candidates: []string{"histsize"}},
+		{line: "select * from u", word: "u", candidates: []string{"users"}},
+		{line: "select * from users, o", word: "o", candidates: []string{"orders"}},
+		{line: "select * from users u wh", word: "wh", candidates: []string{"when", "where"}},
+		{line: "select * from users where na", word: "na", candidates: []string{"name"}},
+		{line: "select * from users u join orders o on o.u", word: "o.u", candidates: []string{"o.user_id"}},
+		{line: "SELECT * FROM users WHERE I", word: "I", candidates: []string{"id", "IF", "IN", "INDEX", "INNER", "INSERT", "INTO", "IS"}},
+		{line: "select ", word: "", candidates: nil},
+		{line: "Sel", word: "Sel", candidates: []string{"Select"}},
+		{line: "select * from users wHE", word: "wHE", candidates: []string{"wHEN", "wHERE"}},
+		{line: "select * from USE", word: "USE", candidates: []string{"users"}},
+	}
+
+	for _, tt := range tests {
+		word, candidates := completer.Complete(tt.line, len([]rune(tt.line)))
+		if word != tt.word || !reflect.DeepEqual(candidates, tt.candidates) {
+			t.Errorf("%q: Complete() = %q %q, expected %q %q", tt.line, word, candidates, tt.word, tt.candidates)
+		}
+	}
+
+	// the cached schema is read again after invalidation
+	db.Exec("CREATE TABLE products (id INTEGER)")
+	if _, candidates := completer.Complete("select * from p", 15); len(candidates) != 0 {
+		t.Errorf("expected the cached schema, got %q", candidates)
+	}
+	completer.Invalidate()
+	if _, candidates := completer.Complete("select * from p", 15); !reflect.DeepEqual(candidates, []string{"products"}) {
+		t.Errorf("expected the new table, got %q", candidates)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"io"
 	"net"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
@@ -29,11 +28,13 @@ func checkDatabase(ctx context.Context, alias string, info pkg.ConnectionInfo, c
 		Database: info.Database,
 	}
 
-	userAlias, ok := config.Credentials[info.UserAlias]
-	if !ok {
-		res.Err = fmt.Errorf("alias not configured: %s", info.UserAlias)
+	info, userAlias, err := config.ResolveConnection(info)
+	if err != nil {
+		res.Err = err
 		return res
 	}
+	res.Host = info.Host
+	res.Database = info.Database
 
 	// Dynamic tunnel setup
 	if info.Tunnel != "" {
@@ -51,19 +52,14 @@ func checkDatabase(ctx context.Context, alias string, info pkg.ConnectionInfo, c
 		defer listener.Close()
 
 		localPort := listener.Addr().(*net.TCPAddr).Port
-		values := strings.SplitN(info.Tunnel, "@", 2)
-		if len(values) < 2 {
-			res.Err = fmt.Errorf("invalid tunnel configuration: %s", info.Tunnel)
+		sshUser, sshAddr, err := pkg.ParseTunnel(info.Tunnel)
+		if err != nil {
+			res.Err = err
 			return res
 		}
 
-		sshAddr := values[1]
-		if !strings.Contains(sshAddr, ":") {
-			sshAddr = fmt.Sprintf("%s:22", sshAddr)
-		}
-
 		go pkg.TunnelInfo{
-			User:       values[0],
+			User:       sshUser,
 			SshAddr:    sshAddr,
 			RemoteAddr: net.JoinHostPort(info.Host, fmt.Sprintf("%d", info.Port)),
 			Agent:      agent,
@@ -73,13 +69,27 @@ func checkDatabase(ctx context.Context, alias string, info pkg.ConnectionInfo, c
 		info.Port = localPort
 	}
 
-	db, err := sql.Open(info.Driver, pkg.Connection{
+	password, err := userAlias.ResolvePassword()
+	if err != nil {
+		res.Err = fmt.Errorf("unable to read password: %w", err)
+		return res
+	}
+
+	dsn, err := pkg.Connection{
 		Username: userAlias.Username,
-		Password: userAlias.Password,
+		Password: password,
 		Host:     info.Host,
 		Port:     info.Port,
 		Database: info.Database,
-	}.Connstring())
+		Params:   info.Params,
+		TLS:      info.TLS,
+	}.DSN(info.Driver)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	db, err := sql.Open(info.Driver, dsn)
 	if err != nil {
 		res.Err = err
 		return res
@@ -96,19 +106,23 @@ func checkDatabase(ctx context.Context, alias string, info pkg.ConnectionInfo, c
 	return res
 }
 
-func RunHealthcheck(config pkg.Config, out io.Writer) error {
+// RunHealthcheck pings the databases with the tag, or all of them when tag is empty
+func RunHealthcheck(config pkg.Config, tag string, out io.Writer) error {
 	type task struct {
 		alias string
 		info  pkg.ConnectionInfo
 	}
 
-	var aliases []string
-	for alias := range config.Databases {
-		aliases = append(aliases, alias)
+	aliases := config.Aliases(tag)
+	if tag != "" && len(aliases) == 0 {
+		return fmt.Errorf("no database tagged %s", tag)
 	}
-	sort.Strings(aliases)
 
-	fmt.Fprintf(out, "Checking %d databases...\n\n", len(aliases))
+	if tag != "" {
+		fmt.Fprintf(out, "Checking %d databases tagged %s...\n\n", len(aliases), tag)
+	} else {
+		fmt.Fprintf(out, "Checking %d databases...\n\n", len(aliases))
+	}
 
 	tasksChan := make(chan task, len(aliases))
 	resultsChan := make(chan HealthcheckResult, len(aliases))
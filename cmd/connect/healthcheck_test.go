@@ -371,7 +371,7 @@ func TestRunHealthcheckReporting(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	err := RunHealthcheck(config, &buf)
+	err := RunHealthcheck(config, "", &buf)
 
 	if err == nil {
 		t.Error("expected error due to failures")
@@ -394,3 +394,23 @@ func TestRunHealthcheckReporting(t *testing.T) {
 		t.Errorf("unexpected summary text: %s", output)
 	}
 }
+
+func TestRunHealthcheckTag(t *testing.T) {
+	config := pkg.Config{
+		Databases: map[string]pkg.ConnectionInfo{
+			"db-a": {Host: "127.0.0.1", Driver: "unknown-driver", Tag: []string{"staging"}},
+			"db-b": {Host: "127.0.0.1", Driver: "unknown-driver", Tag: []string{"production"}},
+		},
+	}
+
+	var buf bytes.Buffer
+	RunHealthcheck(config, "staging", &buf)
+	output := buf.String()
+	if !strings.Contains(output, "db-a") || strings.Contains(output, "db-b") {
+		t.Errorf("expected only the staging database in the report, got: %s", output)
+	}
+
+	if err := RunHealthcheck(config, "missing", &buf); err == nil {
+		t.Error("expected error for a tag without databases")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+// historyDir contains one history file per alias
+func historyDir() string {
+	return pkg.ConfigPath("history")
+}
+
+// legacyHistoryFile is the history shared by every alias before it was split, still shown by \history -a
+func legacyHistoryFile() string {
+	return pkg.ConfigPath("history.txt")
+}
+
+var unsafeFilename = regexp.MustCompile(`[^a-zA-Z0-9._@-]`)
+
+// historyName is the alias recorded in the history. Connection URLs are reduced to driver, host
+// and database, so that passwords written in the URL never reach the history files.
+func historyName(config pkg.Config, alias string, info pkg.ConnectionInfo) string {
+	if _, ok := config.Databases[alias]; ok {
+		return alias
+	}
+	return fmt.Sprintf("%s@%s/%s", info.Driver, info.Host, info.Database)
+}
+
+func historyFile(dir, name string) string {
+	return filepath.Join(dir, unsafeFilename.ReplaceAllString(name, "_")+".jsonl")
+}
+
+func loadHistoryFile(h *terminal.History, filename string) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	h.Load(fd)
+	return nil
+}
+
+// readAllHistory merges the history files of every alias and the legacy history, oldest first
+func readAllHistory(dir, legacy string) []terminal.HistoryEntry {
+	all := terminal.History{}
+	loadHistoryFile(&all, legacy)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	for _, filename := range files {
+		loadHistoryFile(&all, filename)
+	}
+	return all.Entries
+}
+
+// historyTable lists the entries containing the pattern, numbered by their position in the history
+func historyTable(entries []terminal.HistoryEntry, pattern string, showAlias bool) *ResultSet {
+	result := &ResultSet{Headers: []string{"#", "Time", "Duration", "Status", "Command"}}
+	if showAlias {
+		result.Headers = []string{"#", "Time", "Alias", "Duration", "Status", "Command"}
+	}
+
+	pattern = strings.ToLower(pattern)
+	for i, entry := range entries {
+		if !strings.Contains(strings.ToLower(entry.Command), pattern) {
+			continue
+		}
+
+		when, duration, status := "", "", ""
+		if !entry.Time.IsZero() {
+			when = entry.Time.Local().Format("2006-01-02 15:04:05")
+			duration = formatDuration(entry.Duration)
+			status = "error"
+			if entry.Success {
+				status = "ok"
+			}
+		}
+
+		row := []string{strconv.Itoa(i + 1), when, duration, status, entry.Command}
+		if showAlias {
+			row = []string{strconv.Itoa(i + 1), when, entry.Alias, duration, status, entry.Command}
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	return result
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// execHistory lists the history of the alias, or of every alias with -a, and re-runs an entry with !<n>
+func execHistory(tokens []string, db *sql.DB, t *terminal.Terminal, _ map[string]Command, config *pkg.Config) error {
+	// the last entry is this command, still running
+	entries := t.History.Entries
+	if len(entries) > 0 {
+		entries = entries[:len(entries)-1]
+	}
+
+	showAlias := false
+	if len(tokens) > 0 && tokens[0] == "-a" {
+		// the finished commands of the session are already saved
+		entries = readAllHistory(historyDir(), legacyHistoryFile())
+		showAlias = true
+		tokens = tokens[1:]
+	}
+
+	pattern := strings.Join(tokens, " ")
+	if number, ok := strings.CutPrefix(pattern, "!"); ok {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > len(entries) {
+			return fmt.Errorf("sintassi: \\history [-a] !<n>, with n between 1 and %d", len(entries))
+		}
+
+		cmd := entries[n-1].Command
+		// an entry running \history again could refer to itself and never end
+		if fields := strings.Fields(cmd); len(fields) > 0 && fields[0] == "\\history" {
+			return fmt.Errorf("entry %d is a \\history command and cannot be run again", n)
+		}
+		fmt.Printf("%s%s\n", t.Prompt, cmd)
+		execute(cmd, db, t, config)
+		return nil
+	}
+
+	display(historyTable(entries, pattern, showAlias))
+	return nil
+}
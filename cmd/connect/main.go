@@ -6,10 +6,10 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
-	"math/rand"
 	"net"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -17,7 +17,9 @@ import (
 
 	"database/sql"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 
 	"codeberg.org/ale-cci/connect/pkg"
 	"codeberg.org/ale-cci/connect/pkg/terminal"
@@ -29,96 +31,155 @@ func loadconfig(t *terminal.Terminal, c *pkg.Config) {
 	t.History.Size = c.Options.HistSize
 	t.TabSize = c.Options.TabSize
 	t.RowLimit = c.Options.AutoLimit
+
+	t.Highlighter = nil
+	if useColor() && c.Options.Theme != "none" {
+		theme, err := terminal.LoadTheme(c.Options.Theme, c.Options.Colors)
+		if err != nil {
+			slog.Error("invalid theme, syntax highlighting disabled", "err", err)
+			return
+		}
+		t.Highlighter = terminal.SQLHighlighter{Theme: theme}
+	}
+}
+
+// useColor follows https://no-color.org, colors are also left out when the output is not a terminal
+func useColor() bool {
+	return os.Getenv("NO_COLOR") == "" && terminal.IsTerminal(int(os.Stdout.Fd()))
 }
 
 func main() {
-	config, err := pkg.LoadConfig(pkg.ConfigPath("config.yaml"))
+	config, err := pkg.LoadConfig(pkg.DefaultConfigPath())
 
 	if err != nil {
 		slog.Error("Failed to read config file", "err", err)
 		return
 	}
 
-	if len(os.Args) <= 1 {
-		slog.Error("alias obbligatorio per collegarsi a db")
-		os.Exit(1)
-	}
-	alias := os.Args[1]
+	alias := ""
+	tag := ""
+	healthcheck := false
+	for i := 1; i < len(os.Args); i++ {
+		switch arg := os.Args[i]; arg {
+		case "--completions":
+			// "--completions tags" lists the tags, used after --tag
+			if i+1 < len(os.Args) && os.Args[i+1] == "tags" {
+				fmt.Printf("%s", strings.Join(config.Tags(), " "))
+			} else {
+				fmt.Printf("%s", strings.Join(config.Aliases(""), " "))
+			}
+			os.Exit(0)
+
+		case "-v", "--version":
+			fmt.Printf(
+				"connect version %s\n",
+				version,
+			)
+			os.Exit(0)
+
+		case "--healthcheck":
+			healthcheck = true
+
+		case "--tag":
+			if i+1 >= len(os.Args) {
+				slog.Error("Opzione --tag richiede un valore")
+				os.Exit(1)
+			}
+			tag = os.Args[i+1]
+			i++
 
-	if alias == "--completions" {
-		aliases := []string{}
-		for name := range config.Databases {
-			aliases = append(aliases, name)
+		default:
+			alias = arg
 		}
-		fmt.Printf("%s", strings.Join(aliases, " "))
-		os.Exit(0)
 	}
 
-	if alias == "-v" || alias == "--version" {
-		fmt.Printf(
-			"connect version %s\n",
-			version,
-		)
+	if healthcheck {
+		err := RunHealthcheck(config, tag, os.Stdout)
+		if err != nil {
+			slog.Error("healthcheck failed", "err", err)
+			os.Exit(1)
+		}
 		os.Exit(0)
 	}
 
-	if alias == "--healthcheck" {
-		err := RunHealthcheck(config, os.Stdout)
+	recentPath := pkg.ConfigPath("recent.txt")
+	if alias == "" {
+		// without an alias the user picks one, unless stdin is piped
+		alias, err = selectAlias(config, tag, readRecent(recentPath), terminal.IsTerminal(int(os.Stdin.Fd())))
+		if errors.Is(err, terminal.ErrPickerCancelled) {
+			os.Exit(1)
+		}
 		if err != nil {
+			slog.Error("alias obbligatorio per collegarsi a db", "tag", tag, "err", err)
 			os.Exit(1)
 		}
-		os.Exit(0)
+	} else if tag != "" && !slices.Contains(config.Aliases(tag), alias) {
+		slog.Error("database not tagged", "alias", alias, "tag", tag)
+		os.Exit(1)
 	}
 
-	info, ok := config.Databases[alias]
-	if !ok {
-		slog.Error("Alias not found in config file", "alias", alias)
-		return
+	info, userAlias, err := config.Lookup(alias)
+	if err != nil {
+		slog.Error("unable to resolve connection", "alias", alias, "err", err)
+		os.Exit(1)
 	}
+	historyAlias := historyName(config, alias, info)
 	slog.Info("Starting connection to", "host", info.Host, "db", info.Database)
 
 	if info.Tunnel != "" {
-		randomPort := rand.Intn(1000) + 9000
-		slog.Info("Starting tunnel", "host", info.Tunnel, "port", info.Port, "localport", randomPort)
+		sshUser, sshAddr, err := pkg.ParseTunnel(info.Tunnel)
+		if err != nil {
+			slog.Error("invalid tunnel", "err", err)
+			os.Exit(1)
+		}
+
 		agent, err := pkg.AuthAgent()
 		if err != nil {
 			slog.Error("unable to connect to ssh agent", "err", err)
 			os.Exit(1)
 		}
 
-		localAddr := fmt.Sprintf("127.0.0.1:%d", randomPort)
-		listener, err := net.Listen("tcp", localAddr)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
 		if err != nil {
 			slog.Error("failed to start local listener", "err", err)
 			os.Exit(1)
 		}
+		localPort := listener.Addr().(*net.TCPAddr).Port
+		slog.Info("Starting tunnel", "host", info.Tunnel, "port", info.Port, "localport", localPort)
 
-		values := strings.SplitN(info.Tunnel, "@", 2)
 		defer listener.Close()
 		go pkg.TunnelInfo{
-			User:       values[0],
-			SshAddr:    fmt.Sprintf("%s:22", values[1]),
+			User:       sshUser,
+			SshAddr:    sshAddr,
 			RemoteAddr: fmt.Sprintf("%s:%d", info.Host, info.Port),
 			Agent:      agent,
 		}.Start(listener)
 
 		info.Host = "127.0.0.1"
-		info.Port = randomPort
+		info.Port = localPort
 	}
 
-	userAlias, ok := config.Credentials[info.UserAlias]
-	if !ok {
-		slog.Error("alias not configured", "alias", info.UserAlias)
+	password, err := userAlias.ResolvePassword()
+	if err != nil {
+		slog.Error("unable to read password", "alias", info.UserAlias, "err", err)
 		os.Exit(1)
 	}
 
-	db, err := sql.Open(info.Driver, pkg.Connection{
+	dsn, err := pkg.Connection{
 		Username: userAlias.Username,
-		Password: userAlias.Password,
+		Password: password,
 		Host:     info.Host,
 		Port:     info.Port,
 		Database: info.Database,
-	}.Connstring())
+		Params:   info.Params,
+		TLS:      info.TLS,
+	}.DSN(info.Driver)
+	if err != nil {
+		slog.Error("invalid connection parameters", "err", err)
+		os.Exit(1)
+	}
+
+	db, err := sql.Open(info.Driver, dsn)
 
 	if err != nil {
 		slog.Error("Impossibile stabilire connessione a database", "err", err)
@@ -133,6 +194,12 @@ func main() {
 		return
 	}
 
+	if _, ok := config.Databases[alias]; ok {
+		if err := touchRecent(recentPath, alias); err != nil {
+			slog.Debug("Failed to save recent aliases", "err", err)
+		}
+	}
+
 	fdStdin := int(os.Stdin.Fd())
 	if terminal.IsTerminal(fdStdin) {
 		oldState, err := terminal.MakeRaw(fdStdin)
@@ -149,24 +216,14 @@ func main() {
 			State:  oldState,
 		}
 		loadconfig(&t, &config)
+		t.Completer = &sqlCompleter{db: db, driver: info.Driver}
 
-		histfilePath := pkg.ConfigPath("history.txt")
-		fd, err := os.Open(histfilePath)
-		if err == nil {
-			t.History.Load(fd)
-			fd.Close()
-		} else {
+		t.History.Alias = historyAlias
+		histfilePath := historyFile(historyDir(), historyAlias)
+		if err := loadHistoryFile(&t.History, histfilePath); err != nil {
 			slog.Debug("Failed to read history", "err", err)
 		}
 
-		defer func() {
-			fd, err := os.Create(histfilePath)
-			if err == nil {
-				t.History.Save(fd)
-				fd.Close()
-			}
-		}()
-
 		for {
 			cmd, err := t.ReadCmd()
 			if err != nil {
@@ -178,42 +235,18 @@ func main() {
 			}
 			slog.Debug("executing command", "cmd", cmd)
 
-			start := time.Now()
-
-			var result *ResultSet
-
 			if cmd == "" {
 				fmt.Println("^C")
 				continue
 			}
-			if IsCommand(cmd) {
-				result = nil
-				err = RunCommand(cmd, db, &t, &config)
-			} else {
-				if t.RowLimit > 0 {
-					newcmd, replaced := AddLimit(cmd, t.RowLimit)
-					cmd = newcmd
-					if replaced {
-						slog.Info("Autolimit added", "limit", t.RowLimit)
-					}
-				}
-
-				result, err = runQuery(db, cmd)
-			}
 
-			elapsed := time.Since(start)
+			start := time.Now()
+			err = execute(cmd, db, &t, &config)
+			t.History.Finish(time.Since(start), err == nil)
 
-			if err != nil {
-				slog.Error("Error while running query:", "err", err)
-			} else {
-				if result != nil {
-					if len(result.Headers) > 0 {
-						display(result)
-					}
-					slog.Info("Execution completed", "elapsed", elapsed, "rows", len(result.Rows))
-				} else {
-					slog.Info("Execution completed", "elapsed", elapsed)
-				}
+			// appended after every command, so that simultaneous sessions keep each other's history
+			if err := t.History.SaveFile(histfilePath); err != nil {
+				slog.Debug("Failed to save history", "err", err)
 			}
 		}
 	} else {
@@ -269,6 +302,49 @@ func main() {
 	}
 }
 
+// execute runs a query or a slash command typed at the interactive prompt, printing its results
+func execute(cmd string, db *sql.DB, t *terminal.Terminal, config *pkg.Config) error {
+	start := time.Now()
+
+	var result *ResultSet
+	var err error
+
+	if IsCommand(cmd) {
+		err = RunCommand(cmd, db, t, config)
+	} else {
+		if t.RowLimit > 0 {
+			newcmd, replaced := AddLimit(cmd, t.RowLimit)
+			cmd = newcmd
+			if replaced {
+				slog.Info("Autolimit added", "limit", t.RowLimit)
+			}
+		}
+
+		result, err = runQuery(db, cmd)
+	}
+
+	elapsed := time.Since(start)
+
+	// tables and columns may have changed, completion reads the schema again
+	if completer, ok := t.Completer.(*sqlCompleter); ok && err == nil && ddlStatement.MatchString(cmd) {
+		completer.Invalidate()
+	}
+
+	if err != nil {
+		slog.Error("Error while running query:", "err", err)
+		return err
+	}
+	if result != nil {
+		if len(result.Headers) > 0 {
+			display(result)
+		}
+		slog.Info("Execution completed", "elapsed", elapsed, "rows", len(result.Rows))
+	} else {
+		slog.Info("Execution completed", "elapsed", elapsed)
+	}
+	return nil
+}
+
 func AddLimit(cmd string, limit int) (string, bool) {
 	cleanedCmd := strings.TrimRightFunc(strings.ToLower(strings.TrimSpace(cmd)), func(r rune) bool {
 		return unicode.IsDigit(r) || unicode.IsSpace(r) || r == ';'
@@ -423,6 +499,14 @@ func init() {
 			Run:  execSchema,
 			Help: "Salva lo schema (DDL) delle tabelle corrispondenti al pattern in un file schema-pattern-data-ora.sql",
 		},
+		"\\edit": {
+			Run:  execEdit,
+			Help: "Edit the last query in $EDITOR, it runs when saved ending with ; otherwise it waits at the prompt",
+		},
+		"\\history": {
+			Run:  execHistory,
+			Help: "List the history of this alias (-a for every alias) matching a pattern, !<n> runs entry n again",
+		},
 	}
 }
 
@@ -473,6 +557,22 @@ func RunCommand(cmd string, db *sql.DB, t *terminal.Terminal, config *pkg.Config
 	return nil
 }
 
+// execEdit opens the last query in the editor and hands the result to the next prompt
+func execEdit(tokens []string, db *sql.DB, t *terminal.Terminal, _ map[string]Command, config *pkg.Config) error {
+	// the last entry is this command
+	last := ""
+	if n := len(t.History.Strings); n > 1 {
+		last = t.History.Strings[n-2]
+	}
+
+	edited, err := t.EditText(last)
+	if err != nil {
+		return err
+	}
+	t.Preload(edited)
+	return nil
+}
+
 func execHelp(args []string, db *sql.DB, t *terminal.Terminal, commands map[string]Command, config *pkg.Config) error {
 	fmt.Println("Comandi disponibili:")
 
@@ -565,10 +665,22 @@ func execConfig(tokens []string, db *sql.DB, t *terminal.Terminal, _ map[string]
 	return nil
 }
 
+// requireMySQL rejects the commands writing MySQL statements, with backticks and SHOW CREATE TABLE,
+// on the databases of other drivers
+func requireMySQL(db *sql.DB, command string) error {
+	if _, ok := db.Driver().(*mysql.MySQLDriver); !ok {
+		return fmt.Errorf("%s è disponibile solo per database mysql", command)
+	}
+	return nil
+}
+
 func execDump(tokens []string, db *sql.DB, t *terminal.Terminal, _ map[string]Command, config *pkg.Config) error {
 	if len(tokens) == 0 {
 		return fmt.Errorf("sintassi: \\dump <select_query>")
 	}
+	if err := requireMySQL(db, "\\dump"); err != nil {
+		return err
+	}
 
 	query := strings.Join(tokens, " ")
 	tableName := extractTableName(query)
@@ -688,6 +800,9 @@ func execSchema(tokens []string, db *sql.DB, t *terminal.Terminal, _ map[string]
 	if len(tokens) == 0 {
 		return fmt.Errorf("sintassi: \\schema <pattern>")
 	}
+	if err := requireMySQL(db, "\\schema"); err != nil {
+		return err
+	}
 
 	pattern := strings.Join(tokens, " ")
 
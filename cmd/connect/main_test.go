@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"database/sql"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
+
+	"codeberg.org/ale-cci/connect/pkg"
 )
 
 func TestWriteTableSingleLine(t *testing.T) {
@@ -331,3 +336,91 @@ func TestSplitStatements(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectAlias(t *testing.T) {
+	config := pkg.Config{
+		Databases: map[string]pkg.ConnectionInfo{
+			"sales":   {Tag: []string{"production"}},
+			"billing": {Tag: []string{"production"}},
+			"staging": {Tag: []string{"staging"}},
+		},
+	}
+
+	tests := []struct {
+		tag      string
+		expected string
+		fails    bool
+	}{
+		{tag: "staging", expected: "staging"},
+		{tag: "production", fails: true},
+		{tag: "", fails: true},
+		{tag: "missing", fails: true},
+	}
+
+	for _, tt := range tests {
+		got, err := selectAlias(config, tt.tag, nil, false)
+		if tt.fails != (err != nil) {
+			t.Errorf("%s: selectAlias() error = %v, expected failure %v", tt.tag, err, tt.fails)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: selectAlias() = %q, expected %q", tt.tag, got, tt.expected)
+		}
+	}
+}
+
+func TestRankRecent(t *testing.T) {
+	got := rankRecent([]string{"billing", "local", "sales"}, []string{"sales", "removed", "local", "sales"})
+	expected := []string{"sales", "local", "billing"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("rankRecent() = %v, expected %v", got, expected)
+	}
+}
+
+func TestTouchRecent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "recent.txt")
+	for _, alias := range []string{"sales", "billing", "local", "sales"} {
+		if err := touchRecent(filename, alias); err != nil {
+			t.Fatalf("touchRecent() = %v, expected nil", err)
+		}
+	}
+
+	expected := []string{"sales", "local", "billing"}
+	if got := readRecent(filename); !reflect.DeepEqual(got, expected) {
+		t.Errorf("readRecent() = %v, expected %v", got, expected)
+	}
+}
+
+func TestPickerItems(t *testing.T) {
+	config := pkg.Config{
+		Databases: map[string]pkg.ConnectionInfo{
+			"sales":   {Host: "10.0.1.5", Database: "sales", Driver: "mysql", Tag: []string{"production", "eu"}},
+			"billing": {URL: "postgres://pg.internal/billing"},
+		},
+	}
+
+	items := pickerItems(config, []string{"sales", "billing"})
+	expected := []string{
+		"sales    10.0.1.5     sales    mysql     production,eu",
+		"billing  pg.internal  billing  postgres",
+	}
+	for i, item := range items {
+		if item.Label != expected[i] {
+			t.Errorf("pickerItems()[%d] = %q, expected %q", i, item.Label, expected[i])
+		}
+	}
+}
+
+func TestDumpSchemaRequireMySQL(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, command := range []string{"\\dump", "\\schema"} {
+		err := commands[command].Run([]string{"select * from users"}, db, nil, commands, &pkg.Config{})
+		if err == nil || !strings.Contains(err.Error(), "mysql") {
+			t.Errorf("%s: error = %v, expected sqlite to be rejected", command, err)
+		}
+	}
+}
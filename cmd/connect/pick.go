@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"codeberg.org/ale-cci/connect/pkg"
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+// maxRecent is the number of aliases remembered in recent.txt
+const maxRecent = 50
+
+// selectAlias chooses the connection among the aliases with the tag, or all of them without a tag:
+// a single match is used directly, otherwise the user picks one with the fuzzy finder, recently used first.
+// Without a terminal the choice is left to the caller.
+func selectAlias(config pkg.Config, tag string, recent []string, interactive bool) (string, error) {
+	aliases := config.Aliases(tag)
+	switch {
+	case len(aliases) == 0 && tag != "":
+		return "", fmt.Errorf("no database tagged %s", tag)
+	case len(aliases) == 0:
+		return "", fmt.Errorf("no database configured")
+	case len(aliases) == 1:
+		return aliases[0], nil
+	case !interactive:
+		return "", fmt.Errorf("%d databases available, specify one of: %s", len(aliases), strings.Join(aliases, ", "))
+	}
+
+	return terminal.Pick("connect> ", pickerItems(config, rankRecent(aliases, recent)))
+}
+
+// rankRecent moves the recently used aliases, most recent first, before the others
+func rankRecent(aliases, recent []string) []string {
+	ranked := []string{}
+	for _, alias := range recent {
+		if slices.Contains(aliases, alias) && !slices.Contains(ranked, alias) {
+			ranked = append(ranked, alias)
+		}
+	}
+	for _, alias := range aliases {
+		if !slices.Contains(ranked, alias) {
+			ranked = append(ranked, alias)
+		}
+	}
+	return ranked
+}
+
+// pickerItems describes the aliases with host, database, driver and tags, aligned in columns
+func pickerItems(config pkg.Config, aliases []string) []terminal.PickerItem {
+	rows := [][]string{}
+	widths := make([]int, 4)
+	for _, alias := range aliases {
+		info := config.Databases[alias]
+		if resolved, _, err := info.Resolve(); err == nil {
+			info = resolved
+		}
+
+		row := []string{alias, info.Host, info.Database, info.Driver, strings.Join(info.Tag, ",")}
+		for i := range widths {
+			widths[i] = max(widths[i], len(row[i]))
+		}
+		rows = append(rows, row)
+	}
+
+	items := []terminal.PickerItem{}
+	for i, row := range rows {
+		label := fmt.Sprintf("%-*s  %-*s  %-*s  %-*s  %s", widths[0], row[0], widths[1], row[1], widths[2], row[2], widths[3], row[3], row[4])
+		items = append(items, terminal.PickerItem{Value: aliases[i], Label: strings.TrimRight(label, " ")})
+	}
+	return items
+}
+
+// readRecent returns the aliases of recent.txt, most recent first
+func readRecent(filename string) []string {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer fd.Close()
+
+	recent := []string{}
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		if alias := strings.TrimSpace(scanner.Text()); alias != "" {
+			recent = append(recent, alias)
+		}
+	}
+	return recent
+}
+
+// touchRecent moves the alias at the top of recent.txt
+func touchRecent(filename, alias string) error {
+	recent := slices.DeleteFunc(readRecent(filename), func(s string) bool { return s == alias })
+	recent = append([]string{alias}, recent...)
+	if len(recent) > maxRecent {
+		recent = recent[:maxRecent]
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, []byte(strings.Join(recent, "\n")+"\n"), 0600)
+}
@@ -8,6 +8,8 @@ import (
 
 	"os/user"
 	"path"
+	"slices"
+	"sort"
 
 	"gopkg.in/yaml.v2"
 )
@@ -18,6 +20,8 @@ type Connection struct {
 	Host     string
 	Port     int
 	Database string
+	Params   map[string]string
+	TLS      *TLSConfig
 }
 
 // [username[:password]@][protocol[(address)]]/dbname[?param1=value1&...&paramN=valueN]
@@ -46,15 +50,27 @@ func (c Connection) Connstring() string {
 
 	buf.WriteByte('/')
 	buf.WriteString(url.PathEscape(c.Database))
+
+	if len(c.Params) > 0 {
+		buf.WriteByte('?')
+		buf.WriteString(encodeParams(c.Params))
+	}
 	return buf.String()
 }
 
+// User holds a credential. The password can be written in plaintext or read from one of the
+// secret sources when the connection is opened, see ResolvePassword.
 type User struct {
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
+	Username     string            `yaml:"username"`
+	Password     string            `yaml:"password"`
+	PasswordCmd  string            `yaml:"password_cmd,omitempty"`
+	PasswordEnv  string            `yaml:"password_env,omitempty"`
+	PasswordFile string            `yaml:"password_file,omitempty"`
+	Keyring      map[string]string `yaml:"keyring,omitempty"`
 }
 
 type ConnectionInfo struct {
+	URL       string   `yaml:"url,omitempty"`
 	Host      string   `yaml:"host"`
 	Port      int      `yaml:"port"`
 	UserAlias string   `yaml:"alias"`
@@ -62,21 +78,95 @@ type ConnectionInfo struct {
 	Tunnel    string   `yaml:"tunnel"`
 	Driver    string   `yaml:"driver"`
 	Tag       []string `yaml:"tag"`
+	Policy    Policy   `yaml:"policy,omitempty"`
+
+	// driver specific parameters, e.g. charset, parseTime, loc, timeout for mysql
+	Params map[string]string `yaml:"params,omitempty"`
+	TLS    *TLSConfig        `yaml:"tls,omitempty"`
 }
 
 type ConfigOptions struct {
 	AutoLimit int `yaml:"autolimit"`
 	HistSize  int `yaml:"histsize"`
 	TabSize   int `yaml:"tabsize"`
+
+	// syntax highlighting theme of the client, colors override it by token kind
+	Theme  string            `yaml:"theme,omitempty"`
+	Colors map[string]string `yaml:"colors,omitempty"`
 }
 
 type Config struct {
+	Include     []string                  `yaml:"include,omitempty"`
 	Credentials map[string]User           `yaml:"credentials"`
 	Databases   map[string]ConnectionInfo `yaml:"databases"`
 	Options     ConfigOptions             `yaml:"options"`
+	Policy      Policy                    `yaml:"policy,omitempty"`
+
+	// path of the encrypted credentials file next to the configuration, if present
+	encryptedCredentials string
+}
+
+// HasEncryptedCredentials reports whether the credentials.enc file was found next to the configuration
+func (c Config) HasEncryptedCredentials() bool {
+	return c.encryptedCredentials != ""
+}
+
+// Aliases returns the sorted aliases of the databases with the tag, or of all databases when tag is empty
+func (c Config) Aliases(tag string) []string {
+	aliases := []string{}
+	for alias, info := range c.Databases {
+		if tag == "" || slices.Contains(info.Tag, tag) {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
 }
 
+// Tags returns the sorted list of tags used by the databases
+func (c Config) Tags() []string {
+	tags := []string{}
+	for _, info := range c.Databases {
+		for _, tag := range info.Tag {
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// LoadConfig reads the configuration file together with its includes and the project-local
+// .connect.yaml, expanding ${VAR} references. Use ReadConfigFile to edit the file itself.
 func LoadConfig(filepath string) (cnf Config, err error) {
+	cnf, err = loadResolved(filepath, map[string]bool{})
+	if err != nil {
+		return
+	}
+
+	if project := FindProjectConfig(); project != "" {
+		var override Config
+		override, err = loadResolved(project, map[string]bool{})
+		if err != nil {
+			return
+		}
+		if err = checkProjectConfig(cnf, &override, project); err != nil {
+			return
+		}
+		cnf.Merge(override)
+	}
+
+	encrypted := path.Join(path.Dir(filepath), EncryptedCredentialsFile)
+	if _, statErr := os.Stat(encrypted); statErr == nil {
+		cnf.encryptedCredentials = encrypted
+	}
+	return
+}
+
+// ReadConfigFile parses a single configuration file as written on disk,
+// without includes, project overrides or environment variable expansion.
+func ReadConfigFile(filepath string) (cnf Config, err error) {
 	yamlFile, err := os.ReadFile(filepath)
 	if err != nil {
 		return
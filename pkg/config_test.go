@@ -1,5 +1,6 @@
 package pkg_test
 
+import "reflect"
 import "testing"
 import "codeberg.org/ale-cci/connect/pkg"
 
@@ -44,3 +45,36 @@ func TestDSNFormatting(t *testing.T) {
 		}
 	}
 }
+
+func TestAliasesAndTags(t *testing.T) {
+	config := pkg.Config{
+		Databases: map[string]pkg.ConnectionInfo{
+			"sales":   {Tag: []string{"production", "eu"}},
+			"billing": {Tag: []string{"production"}},
+			"staging": {Tag: []string{"staging", "eu"}},
+			"local":   {},
+		},
+	}
+
+	table := []struct {
+		tag    string
+		expect []string
+	}{
+		{tag: "", expect: []string{"billing", "local", "sales", "staging"}},
+		{tag: "production", expect: []string{"billing", "sales"}},
+		{tag: "eu", expect: []string{"sales", "staging"}},
+		{tag: "missing", expect: []string{}},
+	}
+
+	for _, tt := range table {
+		got := config.Aliases(tt.tag)
+		if !reflect.DeepEqual(tt.expect, got) {
+			t.Errorf("tag %q: expect %v, got %v", tt.tag, tt.expect, got)
+		}
+	}
+
+	expect := []string{"eu", "production", "staging"}
+	if got := config.Tags(); !reflect.DeepEqual(expect, got) {
+		t.Errorf("expect tags %v, got %v", expect, got)
+	}
+}
@@ -0,0 +1,187 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"sync"
+
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v2"
+)
+
+const EncryptedCredentialsFile = "credentials.enc"
+
+// file layout: magic | salt | nonce | AES-256-GCM sealed yaml of the credentials map
+var encryptedMagic = []byte("connect-credentials-v1\n")
+
+const (
+	saltSize    = 16
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	scryptKeyLn = 32
+)
+
+// PassphraseEnv can hold the master passphrase when no terminal is available (e.g. connect-mcp started by an LLM client)
+const PassphraseEnv = "CONNECT_PASSPHRASE"
+
+// PromptPassphrase asks the master passphrase, it can be replaced to read it from somewhere else
+var PromptPassphrase = func() (string, error) {
+	if value, ok := os.LookupEnv(PassphraseEnv); ok {
+		return value, nil
+	}
+	return terminal.ReadPassword("Master passphrase: ")
+}
+
+var (
+	credentialsMu sync.Mutex
+	passphrase    string
+	decrypted     = map[string]map[string]User{}
+)
+
+func deriveKey(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptKeyLn)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptCredentials serializes the credentials and encrypts them with a key derived from the passphrase
+func EncryptCredentials(credentials map[string]User, passphrase string) ([]byte, error) {
+	plaintext, err := yaml.Marshal(credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, plaintext, encryptedMagic), nil
+}
+
+// DecryptCredentials reverses EncryptCredentials
+func DecryptCredentials(data []byte, passphrase string) (map[string]User, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("not an encrypted credentials file")
+	}
+	data = data[len(encryptedMagic):]
+
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("encrypted credentials file is truncated")
+	}
+	salt, data := data[:saltSize], data[saltSize:]
+
+	gcm, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted credentials file is truncated")
+	}
+	nonce, sealed := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, sealed, encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted credentials file")
+	}
+
+	credentials := map[string]User{}
+	err = yaml.Unmarshal(plaintext, &credentials)
+	return credentials, err
+}
+
+// Passphrase returns the master passphrase, prompting for it only the first time in the session
+func Passphrase() (string, error) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+	return cachedPassphrase()
+}
+
+func cachedPassphrase() (string, error) {
+	if passphrase != "" {
+		return passphrase, nil
+	}
+
+	value, err := PromptPassphrase()
+	if err != nil {
+		return "", err
+	}
+	passphrase = value
+	return passphrase, nil
+}
+
+// LoadEncryptedCredentials decrypts the credentials file, caching the result for the session
+func LoadEncryptedCredentials(filepath string) (map[string]User, error) {
+	credentialsMu.Lock()
+	defer credentialsMu.Unlock()
+
+	if credentials, ok := decrypted[filepath]; ok {
+		return credentials, nil
+	}
+
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	pass, err := cachedPassphrase()
+	if err != nil {
+		return nil, err
+	}
+
+	credentials, err := DecryptCredentials(data, pass)
+	if err != nil {
+		// do not keep a wrong passphrase around
+		passphrase = ""
+		return nil, err
+	}
+
+	decrypted[filepath] = credentials
+	return credentials, nil
+}
+
+// Credential looks up a credential alias, falling back to the encrypted credentials file
+// next to the configuration, which is decrypted only when a credential is missing from config.yaml.
+func (c Config) Credential(alias string) (User, error) {
+	if user, ok := c.Credentials[alias]; ok {
+		return user, nil
+	}
+
+	if c.encryptedCredentials != "" {
+		credentials, err := LoadEncryptedCredentials(c.encryptedCredentials)
+		if err != nil {
+			return User{}, fmt.Errorf("unable to decrypt %s: %w", EncryptedCredentialsFile, err)
+		}
+		if user, ok := credentials[alias]; ok {
+			return user, nil
+		}
+	}
+	return User{}, fmt.Errorf("alias not configured: %s", alias)
+}
@@ -0,0 +1,94 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestEncryptCredentialsRoundtrip(t *testing.T) {
+	credentials := map[string]pkg.User{
+		"prod_admin": {Username: "admin", Password: "SuperSecurePassword123"},
+	}
+
+	data, err := pkg.EncryptCredentials(credentials, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "SuperSecurePassword123") {
+		t.Fatalf("expected password not to appear in the encrypted file")
+	}
+
+	got, err := pkg.DecryptCredentials(data, "passphrase")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if !reflect.DeepEqual(got, credentials) {
+		t.Errorf("expect %v, got %v", credentials, got)
+	}
+
+	if _, err := pkg.DecryptCredentials(data, "wrong"); err == nil {
+		t.Errorf("expected error with wrong passphrase")
+	}
+}
+
+func TestConfigCredentialFallsBackToEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	err := os.WriteFile(configPath, []byte("credentials:\n  plain:\n    username: dev\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := pkg.EncryptCredentials(map[string]pkg.User{
+		"secret": {Username: "admin", Password: "pwd"},
+	}, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, pkg.EncryptedCredentialsFile), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	prompts := 0
+	origPrompt := pkg.PromptPassphrase
+	pkg.PromptPassphrase = func() (string, error) {
+		prompts++
+		return "passphrase", nil
+	}
+	defer func() { pkg.PromptPassphrase = origPrompt }()
+
+	config, err := pkg.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := config.Credential("plain"); err != nil {
+		t.Errorf("expected nil error, got %v", err)
+	}
+	if prompts != 0 {
+		t.Errorf("expected no prompt for plaintext credentials, got %d", prompts)
+	}
+
+	for i := 0; i < 2; i++ {
+		user, err := config.Credential("secret")
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if user.Password != "pwd" {
+			t.Errorf("expect decrypted password, got %q", user.Password)
+		}
+	}
+	if prompts != 1 {
+		t.Errorf("expected passphrase to be asked once, got %d", prompts)
+	}
+
+	if _, err := config.Credential("missing"); err == nil || !strings.Contains(err.Error(), "alias not configured") {
+		t.Errorf("expected alias not configured error, got %v", err)
+	}
+}
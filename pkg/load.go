@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ConfigEnv overrides the path of the main configuration file for every binary
+	ConfigEnv = "CONNECT_CONFIG"
+	// ProjectConfigFile is looked up from the working directory upward and merged on top of the configuration
+	ProjectConfigFile = ".connect.yaml"
+)
+
+var envVarRe = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// DefaultConfigPath returns $CONNECT_CONFIG when set, ~/.config/connect/config.yaml otherwise
+func DefaultConfigPath() string {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	return ConfigPath("config.yaml")
+}
+
+// FindProjectConfig walks from the working directory up to the root looking for .connect.yaml
+func FindProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		candidate := filepath.Join(dir, ProjectConfigFile)
+		if stat, err := os.Stat(candidate); err == nil && !stat.IsDir() {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// checkProjectConfig guards against a .connect.yaml coming with a cloned repository: it cannot define
+// credentials, whose secret sources may run commands, nor use the credentials of the user. The aliases
+// of the user configuration keep their connection fields, left empty they are inherited, and new
+// aliases cannot reference a credential, otherwise its password would be sent to the project's host.
+func checkProjectConfig(cnf Config, project *Config, path string) error {
+	if len(project.Credentials) > 0 {
+		return fmt.Errorf("%s: a project configuration cannot define credentials", path)
+	}
+
+	for alias, info := range project.Databases {
+		current, ok := cnf.Databases[alias]
+		if !ok {
+			resolved, _, err := info.Resolve()
+			if err != nil {
+				return fmt.Errorf("%s: databases.%s: %w", path, alias, err)
+			}
+			if resolved.UserAlias != "" {
+				return fmt.Errorf("%s: a project configuration cannot use the credential %s in %s", path, resolved.UserAlias, alias)
+			}
+			continue
+		}
+
+		info.Host = cmp.Or(info.Host, current.Host)
+		info.Port = cmp.Or(info.Port, current.Port)
+		info.Tunnel = cmp.Or(info.Tunnel, current.Tunnel)
+		info.URL = cmp.Or(info.URL, current.URL)
+		info.UserAlias = cmp.Or(info.UserAlias, current.UserAlias)
+		info.Driver = cmp.Or(info.Driver, current.Driver)
+		if info.Params == nil {
+			info.Params = current.Params
+		}
+		if info.TLS == nil {
+			info.TLS = current.TLS
+		}
+
+		if info.Host != current.Host || info.Port != current.Port || info.Tunnel != current.Tunnel ||
+			info.URL != current.URL || info.UserAlias != current.UserAlias || info.Driver != current.Driver ||
+			!reflect.DeepEqual(info.Params, current.Params) || !reflect.DeepEqual(info.TLS, current.TLS) {
+			return fmt.Errorf("%s: a project configuration cannot change host, port, tunnel, url, tls, alias, driver or params of %s", path, alias)
+		}
+		project.Databases[alias] = info
+	}
+	return nil
+}
+
+// expandEnv replaces ${VAR} in every string value of the parsed document, keys are left untouched
+func expandEnv(node any) any {
+	switch v := node.(type) {
+	case string:
+		return envVarRe.ReplaceAllStringFunc(v, func(ref string) string {
+			return os.Getenv(envVarRe.FindStringSubmatch(ref)[1])
+		})
+	case map[any]any:
+		for key, value := range v {
+			v[key] = expandEnv(value)
+		}
+	case []any:
+		for i, value := range v {
+			v[i] = expandEnv(value)
+		}
+	}
+	return node
+}
+
+// loadResolved reads a configuration file with ${VAR} expansion and merges its includes on top of it.
+// Include globs are relative to the directory of the file declaring them.
+func loadResolved(path string, visited map[string]bool) (cnf Config, err error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return
+	}
+	if visited[abs] {
+		return cnf, fmt.Errorf("include cycle detected on %s", path)
+	}
+	visited[abs] = true
+	defer delete(visited, abs)
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var document any
+	if err = yaml.Unmarshal(content, &document); err != nil {
+		return cnf, fmt.Errorf("%s: %w", path, err)
+	}
+
+	expanded, err := yaml.Marshal(expandEnv(document))
+	if err != nil {
+		return
+	}
+	if err = yaml.Unmarshal(expanded, &cnf); err != nil {
+		return cnf, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, pattern := range cnf.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return cnf, fmt.Errorf("%s: invalid include %q: %w", path, pattern, err)
+		}
+		sort.Strings(matches)
+
+		for _, match := range matches {
+			included, err := loadResolved(match, visited)
+			if err != nil {
+				return cnf, err
+			}
+			cnf.Merge(included)
+		}
+	}
+	return cnf, nil
+}
+
+// Merge applies other on top of the configuration: credentials and databases are replaced by alias,
+// options set in other override the current ones and policies are combined.
+func (c *Config) Merge(other Config) {
+	if len(other.Credentials) > 0 && c.Credentials == nil {
+		c.Credentials = map[string]User{}
+	}
+	for alias, user := range other.Credentials {
+		c.Credentials[alias] = user
+	}
+
+	if len(other.Databases) > 0 && c.Databases == nil {
+		c.Databases = map[string]ConnectionInfo{}
+	}
+	for alias, info := range other.Databases {
+		c.Databases[alias] = info
+	}
+
+	if other.Options.AutoLimit != 0 {
+		c.Options.AutoLimit = other.Options.AutoLimit
+	}
+	if other.Options.HistSize != 0 {
+		c.Options.HistSize = other.Options.HistSize
+	}
+	if other.Options.TabSize != 0 {
+		c.Options.TabSize = other.Options.TabSize
+	}
+	if other.Options.Theme != "" {
+		c.Options.Theme = other.Options.Theme
+	}
+	if len(other.Options.Colors) > 0 && c.Options.Colors == nil {
+		c.Options.Colors = map[string]string{}
+	}
+	for kind, color := range other.Options.Colors {
+		c.Options.Colors[kind] = color
+	}
+
+	c.Policy = c.Policy.Merge(other.Policy)
+}
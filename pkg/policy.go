@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"slices"
+	"strings"
+)
+
+const (
+	MaskRedact = "redact"
+	MaskHash   = "hash"
+
+	redactedValue = "[REDACTED]"
+)
+
+// MaskRule hides the values of the columns matching Column.
+// Column is a glob matched against the column name, or against "table.column" when it contains a dot.
+type MaskRule struct {
+	Column   string `yaml:"column"`
+	Strategy string `yaml:"strategy"`
+}
+
+// Policy restricts which tables and columns are exposed to clients such as connect-mcp.
+// Table names are matched with path.Match globs, case insensitively.
+type Policy struct {
+	AllowTables []string   `yaml:"allow_tables"`
+	DenyTables  []string   `yaml:"deny_tables"`
+	Mask        []MaskRule `yaml:"mask"`
+
+	// allow lists of the merged policies after the first one, a table must match all of them
+	moreAllowTables [][]string
+}
+
+// Merge returns a policy enforcing the rules of both p and other. Allow lists narrow each other:
+// a table is visible only when it matches the allow list of both policies.
+func (p Policy) Merge(other Policy) Policy {
+	merged := Policy{
+		AllowTables:     p.AllowTables,
+		DenyTables:      append(append([]string{}, p.DenyTables...), other.DenyTables...),
+		Mask:            append(append([]MaskRule{}, p.Mask...), other.Mask...),
+		moreAllowTables: append([][]string{}, p.moreAllowTables...),
+	}
+	for _, allow := range append([][]string{other.AllowTables}, other.moreAllowTables...) {
+		switch {
+		case len(allow) == 0:
+		case len(merged.AllowTables) == 0:
+			merged.AllowTables = allow
+		default:
+			merged.moreAllowTables = append(merged.moreAllowTables, allow)
+		}
+	}
+	return merged
+}
+
+func matchGlob(pattern, name string) bool {
+	ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(name))
+	return err == nil && ok
+}
+
+// AllowsTable reports whether the table can be accessed: denied tables are always hidden,
+// and when allow lists are configured only the tables matching every one of them are visible.
+func (p Policy) AllowsTable(table string) bool {
+	for _, pattern := range p.DenyTables {
+		if matchGlob(pattern, table) {
+			return false
+		}
+	}
+
+	for _, allow := range append([][]string{p.AllowTables}, p.moreAllowTables...) {
+		if len(allow) > 0 && !slices.ContainsFunc(allow, func(pattern string) bool { return matchGlob(pattern, table) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Restricted reports whether the policy has any rule
+func (p Policy) Restricted() bool {
+	return len(p.AllowTables) > 0 || len(p.DenyTables) > 0 || len(p.Mask) > 0
+}
+
+// MaskStrategy returns the strategy to apply to the column, or an empty string if it is not masked.
+// An empty table only matches rules without a table part.
+func (p Policy) MaskStrategy(table, column string) string {
+	return p.maskStrategy(table, column, false)
+}
+
+// MaskStrategyAnyTable returns the strategy to apply to the column when its table is unknown,
+// rules with a table part match the column of any table
+func (p Policy) MaskStrategyAnyTable(column string) string {
+	return p.maskStrategy("", column, true)
+}
+
+func (p Policy) maskStrategy(table, column string, anyTable bool) string {
+	for _, rule := range p.Mask {
+		matched := false
+		if tablePattern, columnPattern, ok := strings.Cut(rule.Column, "."); ok {
+			matched = (anyTable || table != "" && matchGlob(tablePattern, table)) && matchGlob(columnPattern, column)
+		} else {
+			matched = matchGlob(rule.Column, column)
+		}
+
+		if matched {
+			if rule.Strategy == "" {
+				return MaskRedact
+			}
+			return rule.Strategy
+		}
+	}
+	return ""
+}
+
+// MaskValue hides a value according to the strategy. Hashing keeps equal values equal,
+// so masked columns can still be grouped or compared. Unknown strategies redact the value.
+func MaskValue(strategy string, value string) string {
+	switch strategy {
+	case "":
+		return value
+	case MaskHash:
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	default:
+		return redactedValue
+	}
+}
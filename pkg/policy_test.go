@@ -0,0 +1,121 @@
+package pkg_test
+
+import (
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestPolicyAllowsTable(t *testing.T) {
+	table := []struct {
+		policy pkg.Policy
+		table  string
+		expect bool
+	}{
+		{policy: pkg.Policy{}, table: "users", expect: true},
+		{policy: pkg.Policy{DenyTables: []string{"*_passwords"}}, table: "users_passwords", expect: false},
+		{policy: pkg.Policy{DenyTables: []string{"*_passwords"}}, table: "USERS_PASSWORDS", expect: false},
+		{policy: pkg.Policy{DenyTables: []string{"*_passwords"}}, table: "users", expect: true},
+		{policy: pkg.Policy{AllowTables: []string{"orders*"}}, table: "order_items", expect: false},
+		{policy: pkg.Policy{AllowTables: []string{"orders*"}}, table: "orders_2024", expect: true},
+		{
+			policy: pkg.Policy{AllowTables: []string{"orders*"}, DenyTables: []string{"orders_archive"}},
+			table:  "orders_archive",
+			expect: false,
+		},
+	}
+
+	for _, tt := range table {
+		got := tt.policy.AllowsTable(tt.table)
+		if got != tt.expect {
+			t.Errorf("AllowsTable(%q) with %+v: expect %v, got %v", tt.table, tt.policy, tt.expect, got)
+		}
+	}
+}
+
+func TestPolicyMaskStrategy(t *testing.T) {
+	policy := pkg.Policy{
+		Mask: []pkg.MaskRule{
+			{Column: "email", Strategy: pkg.MaskHash},
+			{Column: "users.phone*"},
+		},
+	}
+
+	table := []struct {
+		table  string
+		column string
+		expect string
+	}{
+		{table: "users", column: "email", expect: pkg.MaskHash},
+		{table: "", column: "email", expect: pkg.MaskHash},
+		{table: "users", column: "phone_number", expect: pkg.MaskRedact},
+		{table: "orders", column: "phone_number", expect: ""},
+		{table: "", column: "phone_number", expect: ""},
+		{table: "users", column: "name", expect: ""},
+	}
+
+	for _, tt := range table {
+		got := policy.MaskStrategy(tt.table, tt.column)
+		if got != tt.expect {
+			t.Errorf("MaskStrategy(%q, %q): expect %q, got %q", tt.table, tt.column, tt.expect, got)
+		}
+	}
+
+	if got := policy.MaskStrategyAnyTable("phone_number"); got != pkg.MaskRedact {
+		t.Errorf("MaskStrategyAnyTable(%q): expect %q, got %q", "phone_number", pkg.MaskRedact, got)
+	}
+	if got := policy.MaskStrategyAnyTable("name"); got != "" {
+		t.Errorf("MaskStrategyAnyTable(%q): expect no strategy, got %q", "name", got)
+	}
+}
+
+func TestMaskValue(t *testing.T) {
+	if got := pkg.MaskValue("", "alice@example.com"); got != "alice@example.com" {
+		t.Errorf("expect value untouched, got %v", got)
+	}
+
+	if got := pkg.MaskValue(pkg.MaskRedact, "alice@example.com"); strings.Contains(got, "alice") {
+		t.Errorf("expect value redacted, got %v", got)
+	}
+
+	first := pkg.MaskValue(pkg.MaskHash, "alice@example.com")
+	second := pkg.MaskValue(pkg.MaskHash, "alice@example.com")
+	if first != second || strings.Contains(first, "alice") {
+		t.Errorf("expect stable hash, got %v and %v", first, second)
+	}
+}
+
+func TestPolicyMerge(t *testing.T) {
+	global := pkg.Policy{DenyTables: []string{"secrets"}}
+	local := pkg.Policy{DenyTables: []string{"audit"}}
+
+	merged := global.Merge(local)
+	if merged.AllowsTable("secrets") || merged.AllowsTable("audit") {
+		t.Errorf("expect both tables denied, got %+v", merged)
+	}
+	if len(global.DenyTables) != 1 {
+		t.Errorf("expect merge not to modify the receiver, got %+v", global)
+	}
+
+	// a database allow list narrows the global one
+	allowed := pkg.Policy{AllowTables: []string{"orders*", "users"}}.Merge(pkg.Policy{AllowTables: []string{"orders", "secrets"}})
+	table := []struct {
+		table  string
+		expect bool
+	}{
+		{table: "orders", expect: true},
+		{table: "orders_archive", expect: false},
+		{table: "users", expect: false},
+		{table: "secrets", expect: false},
+	}
+	for _, tt := range table {
+		if got := allowed.AllowsTable(tt.table); got != tt.expect {
+			t.Errorf("AllowsTable(%q) after merge: expect %v, got %v", tt.table, tt.expect, got)
+		}
+	}
+	single := pkg.Policy{}.Merge(pkg.Policy{AllowTables: []string{"orders"}}).Merge(pkg.Policy{})
+	if !single.AllowsTable("orders") || single.AllowsTable("users") {
+		t.Errorf("expect a single allow list to be kept by merge")
+	}
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// ResolvePassword returns the password of the credential, reading it from the configured secret source.
+// The plaintext password takes precedence, then the environment variable, the file, the command and
+// finally the freedesktop Secret Service (queried through secret-tool).
+func (u User) ResolvePassword() (string, error) {
+	switch {
+	case u.Password != "":
+		return u.Password, nil
+
+	case u.PasswordEnv != "":
+		value, ok := os.LookupEnv(u.PasswordEnv)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", u.PasswordEnv)
+		}
+		return value, nil
+
+	case u.PasswordFile != "":
+		content, err := os.ReadFile(expandHome(u.PasswordFile))
+		if err != nil {
+			return "", fmt.Errorf("unable to read password file: %w", err)
+		}
+		return trimNewline(string(content)), nil
+
+	case u.PasswordCmd != "":
+		// like pass(1), only the first line of the output holds the password
+		output, err := runSecretCmd(exec.Command("sh", "-c", u.PasswordCmd))
+		first, _, _ := strings.Cut(output, "\n")
+		return trimNewline(first), err
+
+	case len(u.Keyring) > 0:
+		return u.keyringPassword()
+	}
+	return "", nil
+}
+
+// keyringPassword looks up the password in the Secret Service with secret-tool, which exits
+// with an error and no message when no item matches the attributes
+func (u User) keyringPassword() (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", fmt.Errorf("keyring credentials need secret-tool, usually packaged as libsecret-tools: %w", err)
+	}
+
+	args := []string{"lookup"}
+	attributes := []string{}
+	keys := make([]string, 0, len(u.Keyring))
+	for key := range u.Keyring {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, key, u.Keyring[key])
+		attributes = append(attributes, key+"="+u.Keyring[key])
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", args...)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	switch {
+	case err != nil && stderr.Len() == 0:
+		return "", fmt.Errorf("no password found in the keyring for %s", strings.Join(attributes, " "))
+	case err != nil:
+		return "", fmt.Errorf("secret-tool failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return trimNewline(string(output)), nil
+}
+
+func runSecretCmd(cmd *exec.Cmd) (string, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w: %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return trimNewline(string(output)), nil
+}
+
+// trimNewline removes only the trailing line break, passwords may legitimately contain spaces
+func trimNewline(s string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
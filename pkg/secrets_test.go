@@ -0,0 +1,96 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestResolvePassword(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(passwordFile, []byte("from file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONNECT_TEST_PASSWORD", "from env")
+
+	table := []struct {
+		user   pkg.User
+		expect string
+	}{
+		{user: pkg.User{}, expect: ""},
+		{user: pkg.User{Password: "plaintext", PasswordEnv: "CONNECT_TEST_PASSWORD"}, expect: "plaintext"},
+		{user: pkg.User{PasswordEnv: "CONNECT_TEST_PASSWORD"}, expect: "from env"},
+		{user: pkg.User{PasswordFile: passwordFile}, expect: "from file"},
+		{user: pkg.User{PasswordCmd: "printf 'from cmd\\n'"}, expect: "from cmd"},
+		{user: pkg.User{PasswordCmd: "printf ' spaced '"}, expect: " spaced "},
+		{user: pkg.User{PasswordCmd: "printf 'secret\nurl: example.com\n'"}, expect: "secret"},
+	}
+
+	for _, tt := range table {
+		got, err := tt.user.ResolvePassword()
+		if err != nil {
+			t.Errorf("expected nil error for %+v, got %v", tt.user, err)
+			continue
+		}
+		if got != tt.expect {
+			t.Errorf("expect %q, got %q", tt.expect, got)
+		}
+	}
+}
+
+func TestResolvePasswordErrors(t *testing.T) {
+	table := []pkg.User{
+		{PasswordEnv: "CONNECT_TEST_PASSWORD_UNSET"},
+		{PasswordFile: filepath.Join(t.TempDir(), "missing")},
+		{PasswordCmd: "exit 3"},
+	}
+
+	for _, user := range table {
+		_, err := user.ResolvePassword()
+		if err == nil {
+			t.Errorf("expected error for %+v", user)
+		}
+	}
+}
+
+func TestResolvePasswordKeyring(t *testing.T) {
+	user := pkg.User{Keyring: map[string]string{"service": "connect", "account": "dev"}}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := user.ResolvePassword(); err == nil || !strings.Contains(err.Error(), "secret-tool") {
+		t.Errorf("expected an error naming the missing secret-tool, got %v", err)
+	}
+
+	table := []struct {
+		script string
+		expect string
+		err    string
+	}{
+		{script: "[ \"$*\" = 'lookup account dev service connect' ] && echo 'from keyring'", expect: "from keyring"},
+		{script: "exit 1", err: "no password found in the keyring for account=dev service=connect"},
+		{script: "echo 'Cannot autolaunch D-Bus' >&2; exit 1", err: "Cannot autolaunch D-Bus"},
+	}
+
+	for _, tt := range table {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte("#!/bin/sh\n"+tt.script+"\n"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("PATH", dir+":/usr/bin:/bin")
+
+		got, err := user.ResolvePassword()
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error %q, got %v", tt.err, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.expect {
+			t.Errorf("expect %q, got %q, %v", tt.expect, got, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Completer suggests the words to complete on Tab. line is the whole command and pos the position
+// of the cursor in runes, the candidates replace word, the text ending at the cursor, with their case.
+type Completer interface {
+	Complete(line string, pos int) (word string, candidates []string)
+}
+
+// complete extends the word before the cursor with the prefix shared by the candidates, or lists them
+// below the command when it cannot be extended. Without completions the tab is inserted as is.
+func (t *Terminal) complete() {
+	if t.Completer == nil {
+		t.clearCmd()
+		t.insertRune('\t')
+		t.drawCmd()
+		return
+	}
+
+	word, candidates := t.Completer.Complete(t.command(), t.offset())
+	if len(candidates) == 0 {
+		if strings.TrimSpace(word) == "" {
+			t.clearCmd()
+			t.insertRune('\t')
+			t.drawCmd()
+		}
+		return
+	}
+
+	common := commonPrefix(candidates)
+	if len(candidates) == 1 || utf8.RuneCountInString(common) > utf8.RuneCountInString(word) {
+		t.clearCmd()
+		row := t.display[t.pos.row]
+		col := min(t.pos.col, len(row))
+		start := max(col-utf8.RuneCountInString(word), 0)
+
+		line := append([]rune{}, row[:start]...)
+		line = append(line, []rune(common)...)
+		t.pos.col = len(line)
+		t.display[t.pos.row] = append(line, row[col:]...)
+		t.drawCmd()
+		return
+	}
+
+	t.listCandidates(candidates)
+}
+
+// listCandidates prints the candidates in columns below the command, then draws the command again
+func (t *Terminal) listCandidates(candidates []string) {
+	if below := len(t.display) - 1 - t.pos.row; below > 0 {
+		t.buffer = fmt.Appendf(t.buffer, "\x1b[%dB", below)
+	}
+
+	width := t.width()
+	if width <= 0 {
+		width = 80
+	}
+	colWidth := 0
+	for _, candidate := range candidates {
+		colWidth = max(colWidth, utf8.RuneCountInString(candidate)+2)
+	}
+	columns := max(width/colWidth, 1)
+
+	for i, candidate := range candidates {
+		if i%columns == 0 {
+			t.buffer = append(t.buffer, '\r', '\n')
+		}
+		t.buffer = fmt.Appendf(t.buffer, "%-*s", colWidth, candidate)
+	}
+	t.buffer = append(t.buffer, '\r', '\n')
+	t.buffer = append(t.buffer, t.Prompt...)
+	t.drawCmd()
+}
+
+// offset is the position of the cursor in the command, in runes
+func (t *Terminal) offset() int {
+	offset := 0
+	for _, row := range t.display[:t.pos.row] {
+		offset += len(row) + 1
+	}
+	return offset + min(t.pos.col, len(t.display[t.pos.row]))
+}
+
+// commonPrefix returns the prefix shared by the candidates, compared ignoring case and written as in
+// the first candidate, since it replaces the typed word
+func commonPrefix(candidates []string) string {
+	prefix := []rune(candidates[0])
+	for _, candidate := range candidates[1:] {
+		runes := []rune(candidate)
+		n := 0
+		for n < len(prefix) && n < len(runes) && unicode.ToLower(prefix[n]) == unicode.ToLower(runes[n]) {
+			n += 1
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
@@ -0,0 +1,82 @@
+package terminal
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode"
+)
+
+// EditText lets the user change the text in $VISUAL or $EDITOR, vi when both are unset, and returns
+// the saved content. The terminal is back in cooked mode while the editor runs, as when suspended.
+func (t *Terminal) EditText(text string) (string, error) {
+	file, err := os.CreateTemp("", "connect-*.sql")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString(text + "\n")
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// the editor may come with arguments, e.g. "code --wait"
+	editor := cmp.Or(os.Getenv("VISUAL"), os.Getenv("EDITOR"), "vi")
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if t.State != nil {
+		Restore(t.Fd, t.State)
+	}
+	err = cmd.Run()
+	if t.State != nil {
+		if newState, rawErr := MakeRaw(t.Fd); rawErr == nil {
+			t.State = newState
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("editor %s failed: %w", editor, err)
+	}
+
+	content, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRightFunc(string(content), unicode.IsSpace), nil
+}
+
+// Preload sets the command of the next ReadCmd, which returns it right away when it is complete
+func (t *Terminal) Preload(cmd string) {
+	t.preload = cmd
+}
+
+// editCmd opens the command, or the last one of the history when empty, in the editor and loads
+// the result back, reporting whether it is complete and can be run
+func (t *Terminal) editCmd() bool {
+	text := t.command()
+	if text == "" && len(t.History.Strings) > 0 {
+		text = t.History.Strings[len(t.History.Strings)-1]
+	}
+
+	t.clearCmd()
+	t.flush()
+	edited, err := t.EditText(text)
+
+	t.buffer = append(t.buffer, "\r\x1b[0J"...)
+	if err != nil {
+		t.buffer = fmt.Appendf(t.buffer, "%s\r\n", err)
+	} else {
+		t.loadCmd(edited)
+	}
+	t.buffer = append(t.buffer, t.Prompt...)
+	t.drawCmd()
+	return err == nil && t.isCommandComplete()
+}
@@ -0,0 +1,70 @@
+package terminal
+
+import (
+	"sort"
+	"unicode"
+)
+
+// FuzzyScore matches the runes of pattern in order inside s, ignoring case. Runes matched right after
+// the previous one or at the start of a word score higher, so that "sp" prefers "sales_prod" to "shop".
+func FuzzyScore(pattern, s string) (score int, ok bool) {
+	score, _, ok = FuzzyMatch(pattern, s)
+	return score, ok
+}
+
+// FuzzyMatch is FuzzyScore returning also the rune positions of s matched by the pattern
+func FuzzyMatch(pattern, s string) (score int, positions []int, ok bool) {
+	needle := []rune(pattern)
+	if len(needle) == 0 {
+		return 0, nil, true
+	}
+
+	i := 0
+	prevMatch := -2
+	prev := rune(0)
+	for pos, r := range []rune(s) {
+		if i < len(needle) && unicode.ToLower(r) == unicode.ToLower(needle[i]) {
+			score += 1
+			if pos == prevMatch+1 {
+				score += 4
+			}
+			if pos == 0 || !(unicode.IsLetter(prev) || unicode.IsDigit(prev)) {
+				score += 3
+			}
+			positions = append(positions, pos)
+			prevMatch = pos
+			i += 1
+		}
+		prev = r
+	}
+
+	if i < len(needle) {
+		return 0, nil, false
+	}
+	return score, positions, true
+}
+
+// FilterItems returns the items whose label matches the query, best matches first.
+// Items with the same score keep their order, which is the ranking of an empty query.
+func FilterItems(items []PickerItem, query string) []PickerItem {
+	type scored struct {
+		item  PickerItem
+		score int
+	}
+
+	matches := []scored{}
+	for _, item := range items {
+		if score, ok := FuzzyScore(query, item.Label); ok {
+			matches = append(matches, scored{item, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]PickerItem, len(matches))
+	for i, match := range matches {
+		result[i] = match.item
+	}
+	return result
+}
@@ -0,0 +1,254 @@
+package terminal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Highlighter colors the command while it is typed, returning the SGR parameters of each rune
+// (e.g. "1;34" for bold blue), empty for the default style
+type Highlighter interface {
+	Highlight(cmd []rune) []string
+}
+
+// TokenKind is the class of a token of a SQL statement
+type TokenKind int
+
+const (
+	TokenText TokenKind = iota
+	TokenKeyword
+	TokenString
+	TokenNumber
+	TokenComment
+	TokenIdentifier
+)
+
+// TokenKinds are the names of the token kinds used in the themes configuration
+var TokenKinds = map[string]TokenKind{
+	"keyword":    TokenKeyword,
+	"string":     TokenString,
+	"number":     TokenNumber,
+	"comment":    TokenComment,
+	"identifier": TokenIdentifier,
+}
+
+// Token is the span of runes [Start, End) of a statement
+type Token struct {
+	Kind  TokenKind
+	Start int
+	End   int
+}
+
+// SQLKeywords are the keywords highlighted and completed in SQL statements
+var SQLKeywords = []string{
+	"ALL", "ALTER", "AND", "AS", "ASC", "AVG", "BEGIN", "BETWEEN", "BY", "CASE", "COMMIT", "COUNT", "CREATE",
+	"CROSS", "DEFAULT", "DELETE", "DESC", "DESCRIBE", "DISTINCT", "DROP", "ELSE", "END", "EXISTS", "EXPLAIN",
+	"FALSE", "FOREIGN", "FROM", "FULL", "GROUP", "HAVING", "IF", "IN", "INDEX", "INNER", "INSERT", "INTO", "IS",
+	"JOIN", "KEY", "LEFT", "LIKE", "LIMIT", "MAX", "MIN", "NOT", "NULL", "OFFSET", "ON", "OR", "ORDER", "OUTER",
+	"PRIMARY", "REFERENCES", "RENAME", "REPLACE", "RETURNING", "RIGHT", "ROLLBACK", "SELECT", "SET", "SHOW",
+	"SUM", "TABLE", "TABLES", "THEN", "TRUE", "TRUNCATE", "UNION", "UPDATE", "USING", "VALUES", "VIEW", "WHEN",
+	"WHERE", "WITH",
+}
+
+// Theme maps the token kinds to their SGR parameters, kinds missing are not colored
+type Theme map[TokenKind]string
+
+// Themes are the themes selectable with the theme option, "none" disables highlighting
+var Themes = map[string]Theme{
+	"default": {
+		TokenKeyword:    "1;34",
+		TokenString:     "32",
+		TokenNumber:     "33",
+		TokenComment:    "2",
+		TokenIdentifier: "36",
+	},
+	"light": {
+		TokenKeyword:    "1;35",
+		TokenString:     "31",
+		TokenNumber:     "34",
+		TokenComment:    "2;3",
+		TokenIdentifier: "32",
+	},
+	"mono": {
+		TokenKeyword: "1",
+		TokenString:  "4",
+		TokenComment: "2",
+	},
+	"none": {},
+}
+
+var sgrParameters = regexp.MustCompile(`^[0-9;]*$`)
+
+// LoadTheme returns the named theme, "default" when empty, with the colors overriding the SGR
+// parameters of the kinds named in TokenKinds
+func LoadTheme(name string, colors map[string]string) (Theme, error) {
+	if name == "" {
+		name = "default"
+	}
+	base, ok := Themes[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown theme %q", name)
+	}
+
+	theme := Theme{}
+	for kind, sgr := range base {
+		theme[kind] = sgr
+	}
+	for kindName, sgr := range colors {
+		kind, ok := TokenKinds[kindName]
+		if !ok {
+			return nil, fmt.Errorf("unknown token kind %q", kindName)
+		}
+		if !sgrParameters.MatchString(sgr) {
+			return nil, fmt.Errorf("invalid color %q for %s, expected SGR parameters like 1;34", sgr, kindName)
+		}
+		theme[kind] = sgr
+	}
+	return theme, nil
+}
+
+// SQLHighlighter colors SQL statements and slash commands with the theme
+type SQLHighlighter struct {
+	Theme Theme
+}
+
+// Highlight implements Highlighter
+func (h SQLHighlighter) Highlight(cmd []rune) []string {
+	colors := make([]string, len(cmd))
+	for _, token := range TokenizeSQL(cmd) {
+		for i := token.Start; i < token.End; i++ {
+			colors[i] = h.Theme[token.Kind]
+		}
+	}
+	return colors
+}
+
+// TokenizeSQL splits the statement in keywords, strings, numbers, comments and identifiers, quoted
+// or not. Strings and comments left open run until the end, as they do while being typed.
+func TokenizeSQL(cmd []rune) []Token {
+	tokens := []Token{}
+	at := func(i int) rune {
+		if i < len(cmd) {
+			return cmd[i]
+		}
+		return 0
+	}
+
+	for i := 0; i < len(cmd); {
+		start := i
+		r := cmd[i]
+
+		switch {
+		case r == '-' && at(i+1) == '-', r == '#':
+			for i < len(cmd) && cmd[i] != '\n' {
+				i += 1
+			}
+			tokens = append(tokens, Token{TokenComment, start, i})
+
+		case r == '/' && at(i+1) == '*':
+			i += 2
+			for i < len(cmd) && !(cmd[i-1] == '*' && cmd[i] == '/' && i > start+2) {
+				i += 1
+			}
+			i = min(i+1, len(cmd))
+			tokens = append(tokens, Token{TokenComment, start, i})
+
+		case r == '\'', r == '"', r == '`':
+			i = closeQuote(cmd, i)
+			kind := TokenIdentifier
+			if r == '\'' {
+				kind = TokenString
+			}
+			tokens = append(tokens, Token{kind, start, i})
+
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(at(i+1))):
+			for i < len(cmd) && (unicode.IsDigit(cmd[i]) || unicode.IsLetter(cmd[i]) || cmd[i] == '.') {
+				i += 1
+			}
+			tokens = append(tokens, Token{TokenNumber, start, i})
+
+		case isWordStart(r) || (r == '\\' && isWordStart(at(i+1))):
+			i += 1
+			for i < len(cmd) && (isWordStart(cmd[i]) || unicode.IsDigit(cmd[i]) || cmd[i] == '$') {
+				i += 1
+			}
+			kind := TokenIdentifier
+			word := strings.ToUpper(string(cmd[start:i]))
+			if r == '\\' || slices.Contains(SQLKeywords, word) {
+				kind = TokenKeyword
+			}
+			tokens = append(tokens, Token{kind, start, i})
+
+		default:
+			i += 1
+		}
+	}
+	return tokens
+}
+
+// closeQuote returns the position after the quote closing the one at start, doubled quotes and
+// backslashes escape it
+func closeQuote(cmd []rune, start int) int {
+	quote := cmd[start]
+	for i := start + 1; i < len(cmd); i++ {
+		switch {
+		case cmd[i] == '\\' && quote != '`':
+			i += 1
+		case cmd[i] == quote && i+1 < len(cmd) && cmd[i+1] == quote:
+			i += 1
+		case cmd[i] == quote:
+			return i + 1
+		}
+	}
+	return len(cmd)
+}
+
+func isWordStart(r rune) bool {
+	return unicode.IsLetter(r) || r == '_'
+}
+
+// appendHighlighted writes the row expanding tabs like DisplayString, switching style when the
+// color of the runes changes. The escape sequences take no room on screen, so cursor columns
+// are still computed on the runes.
+func appendHighlighted(buffer []byte, row []rune, colors []string, tabsize int) []byte {
+	if tabsize == 0 {
+		tabsize = 8
+	}
+
+	current := ""
+	width := 0
+	for i, r := range row {
+		if colors[i] != current {
+			if current != "" {
+				buffer = append(buffer, "\x1b[0m"...)
+			}
+			if colors[i] != "" {
+				buffer = fmt.Appendf(buffer, "\x1b[%sm", colors[i])
+			}
+			current = colors[i]
+		}
+
+		if r == '\t' {
+			spaces := tabsize - width%tabsize
+			buffer = append(buffer, strings.Repeat(" ", spaces)...)
+			width += spaces
+		} else {
+			buffer = append(buffer, string(r)...)
+			width += 1
+		}
+	}
+	if current != "" {
+		buffer = append(buffer, "\x1b[0m"...)
+	}
+	return buffer
+}
+
+var ansiSequence = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]`)
+
+// DisplayWidth is the number of runes of s shown on screen, ANSI escape sequences excluded
+func DisplayWidth(s string) int {
+	return len([]rune(ansiSequence.ReplaceAllString(s, "")))
+}
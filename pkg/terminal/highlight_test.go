@@ -0,0 +1,104 @@
+package terminal_test
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect []string
+	}{
+		{
+			input:  "select id, 'it''s' from `users` where total > 1.5; -- done",
+			expect: []string{"keyword:select", "identifier:id", "string:'it''s'", "keyword:from", "identifier:`users`", "keyword:where", "identifier:total", "number:1.5", "comment:-- done"},
+		},
+		{
+			input:  "select /* all\ncolumns */ * from t",
+			expect: []string{"keyword:select", "comment:/* all\ncolumns */", "keyword:from", "identifier:t"},
+		},
+		{
+			// strings still being typed run until the end
+			input:  "select 'abc",
+			expect: []string{"keyword:select", "string:'abc"},
+		},
+		{
+			input:  "\\config set tabsize 4",
+			expect: []string{"keyword:\\config", "keyword:set", "identifier:tabsize", "number:4"},
+		},
+		{
+			input:  "select \"weird \\\" name\", x1 from t",
+			expect: []string{"keyword:select", "identifier:\"weird \\\" name\"", "identifier:x1", "keyword:from", "identifier:t"},
+		},
+	}
+
+	kinds := map[terminal.TokenKind]string{}
+	for name, kind := range terminal.TokenKinds {
+		kinds[kind] = name
+	}
+
+	for _, tc := range tt {
+		cmd := []rune(tc.input)
+		got := []string{}
+		for _, token := range terminal.TokenizeSQL(cmd) {
+			got = append(got, kinds[token.Kind]+":"+string(cmd[token.Start:token.End]))
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("TokenizeSQL(%q): expected %q, got %q", tc.input, tc.expect, got)
+		}
+	}
+}
+
+func TestLoadTheme(t *testing.T) {
+	theme, err := terminal.LoadTheme("", map[string]string{"keyword": "4"})
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if theme[terminal.TokenKeyword] != "4" || theme[terminal.TokenString] != terminal.Themes["default"][terminal.TokenString] {
+		t.Errorf("expected the default theme with the keyword color overridden, got %v", theme)
+	}
+	if terminal.Themes["default"][terminal.TokenKeyword] == "4" {
+		t.Errorf("expected the default theme to be left unchanged")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		colors map[string]string
+	}{
+		{name: "solarized"},
+		{colors: map[string]string{"operator": "1"}},
+		{colors: map[string]string{"keyword": "blue"}},
+	} {
+		if _, err := terminal.LoadTheme(tc.name, tc.colors); err == nil {
+			t.Errorf("LoadTheme(%q, %v): expected an error", tc.name, tc.colors)
+		}
+	}
+}
+
+func TestHighlightedCommand(t *testing.T) {
+	output := bytes.Buffer{}
+	term := terminal.Terminal{
+		Input:       *bufio.NewReader(bytes.NewBufferString("select\t1;\r")),
+		Output:      &output,
+		Prompt:      "\x1b[32m>\x1b[0m ",
+		TabSize:     4,
+		Highlighter: terminal.SQLHighlighter{Theme: terminal.Themes["mono"]},
+	}
+
+	cmd, err := term.ReadCmd()
+	if err != nil || cmd != "select\t1;" {
+		t.Fatalf("expected the command, got %q %v", cmd, err)
+	}
+
+	// tabs are expanded and the cursor placed after the visible runes only
+	expect := "\x1b[3G\x1b[0J\x1b[1mselect\x1b[0m  1;\x1b[13G"
+	if !strings.Contains(output.String(), expect) {
+		t.Errorf("expected %q in %q", expect, output.String())
+	}
+}
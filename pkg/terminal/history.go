@@ -2,16 +2,40 @@ package terminal
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
+// HistoryEntry is an executed command together with where, when and how it ran
+type HistoryEntry struct {
+	Command  string        `json:"command"`
+	Time     time.Time     `json:"time"`
+	Alias    string        `json:"alias,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Success  bool          `json:"success"`
+}
+
 type History struct {
 	Strings []string
+	Entries []HistoryEntry
 	idx     int
 	Size    int
+
+	// Alias is recorded in the entries added during the session
+	Alias string
+
+	// number of entries at the end of Entries not written by Save yet
+	unsaved int
 }
 
 func (h *History) Previous() (string, error) {
@@ -44,13 +68,34 @@ func (h *History) ResetCounter() {
 }
 
 func (h *History) Add(s string) {
-	h.Strings = append(h.Strings, s)
+	h.AddEntry(HistoryEntry{Command: s, Time: time.Now(), Alias: h.Alias})
+}
+
+// AddEntry appends an entry keeping its metadata, it is written by the next Save
+func (h *History) AddEntry(entry HistoryEntry) {
+	h.Strings = append(h.Strings, entry.Command)
+	h.Entries = append(h.Entries, entry)
+	h.unsaved += 1
+	h.trim()
+}
 
-	// trim history
+func (h *History) trim() {
 	if h.Size > 0 {
 		start := max(len(h.Strings)-h.Size, 0)
 		h.Strings = h.Strings[start:]
+		h.Entries = h.Entries[start:]
 	}
+	h.unsaved = min(h.unsaved, len(h.Entries))
+}
+
+// Finish records the outcome of the last added command
+func (h *History) Finish(duration time.Duration, success bool) {
+	if len(h.Entries) == 0 {
+		return
+	}
+	last := &h.Entries[len(h.Entries)-1]
+	last.Duration = duration
+	last.Success = success
 }
 
 func (h *History) Search(s string) (string, error) {
@@ -67,21 +112,171 @@ func (h *History) Search(s string) (string, error) {
 	}
 }
 
+// SearchMatch is a command found by FuzzySearch, Positions are the indexes of its matched runes
+type SearchMatch struct {
+	Command   string
+	Positions []int
+}
+
+// FuzzySearch returns the distinct commands matching the pattern, best matches first and most recent among equals
+func (h *History) FuzzySearch(pattern string) []SearchMatch {
+	type scored struct {
+		match SearchMatch
+		score int
+	}
+
+	seen := map[string]bool{}
+	matches := []scored{}
+	for i := len(h.Strings) - 1; i >= 0; i-- {
+		cmd := h.Strings[i]
+		if seen[cmd] {
+			continue
+		}
+		seen[cmd] = true
+
+		if score, positions, ok := FuzzyMatch(pattern, cmd); ok {
+			matches = append(matches, scored{SearchMatch{cmd, positions}, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]SearchMatch, len(matches))
+	for i, match := range matches {
+		result[i] = match.match
+	}
+	return result
+}
+
+// Save writes the entries added since the last Save, one JSON encoded entry per line
 func (h *History) Save(fd io.Writer) {
-	for _, cmd := range h.Strings {
-		toWrite := base64.StdEncoding.EncodeToString([]byte(cmd))
-		fd.Write([]byte(toWrite))
-		fd.Write([]byte("\n"))
+	encoder := json.NewEncoder(fd)
+	for _, entry := range h.Entries[len(h.Entries)-h.unsaved:] {
+		encoder.Encode(entry)
 	}
+	h.unsaved = 0
 }
 
+// Load merges the entries written by Save, as well as the base64 lines of older history files,
+// with the ones already in memory. Entries present in both are kept once and the result is trimmed to Size.
 func (h *History) Load(fd io.Reader) {
 	reader := bufio.NewScanner(fd)
+	reader.Buffer(nil, 1024*1024)
+
+	loaded := []HistoryEntry{}
 	for reader.Scan() {
-		cmd, err := base64.StdEncoding.DecodeString(reader.Text())
-		if err == nil {
-			h.Add(string(cmd))
+		if entry, ok := parseHistoryLine(reader.Text()); ok {
+			loaded = append(loaded, entry)
+		}
+	}
+	h.merge(loaded)
+}
+
+// merge sorts the loaded and saved entries by time, the unsaved ones stay at the end to be written by Save
+func (h *History) merge(loaded []HistoryEntry) {
+	pending := h.Entries[len(h.Entries)-h.unsaved:]
+	entries := append(loaded, h.Entries[:len(h.Entries)-h.unsaved]...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	// entries without a time come from old history files and are never merged
+	seen := map[HistoryEntry]bool{}
+	merged := []HistoryEntry{}
+	for _, entry := range entries {
+		if !entry.Time.IsZero() {
+			if seen[entry] {
+				continue
+			}
+			seen[entry] = true
 		}
+		merged = append(merged, entry)
 	}
+	merged = append(merged, pending...)
 
+	h.Entries = merged
+	h.Strings = make([]string, len(merged))
+	for i, entry := range merged {
+		h.Strings[i] = entry.Command
+	}
+	h.trim()
+	h.ResetCounter()
+}
+
+// SaveFile appends the unsaved entries to the file while holding an exclusive lock on filename.lock,
+// so that simultaneous sessions add to the file instead of overwriting each other. Once the file grows
+// past twice Size entries it is rewritten with the most recent ones.
+func (h *History) SaveFile(filename string) error {
+	if h.unsaved == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+		return err
+	}
+
+	lock, err := os.OpenFile(filename+".lock", os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+	if err := unix.Flock(int(lock.Fd()), unix.LOCK_EX); err != nil {
+		return err
+	}
+
+	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	h.Save(fd)
+	if err := fd.Close(); err != nil {
+		return err
+	}
+
+	if h.Size > 0 {
+		return compactHistory(filename, h.Size)
+	}
+	return nil
+}
+
+// compactHistory keeps the last size entries of the file, expects the lock to be held
+func compactHistory(filename string, size int) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	if bytes.Count(content, []byte("\n")) <= 2*size {
+		return nil
+	}
+
+	compacted := History{Size: size}
+	compacted.Load(bytes.NewReader(content))
+	compacted.unsaved = len(compacted.Entries)
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	compacted.Save(tmp)
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func parseHistoryLine(line string) (HistoryEntry, bool) {
+	var entry HistoryEntry
+	if strings.HasPrefix(line, "{") {
+		err := json.Unmarshal([]byte(line), &entry)
+		return entry, err == nil
+	}
+
+	cmd, err := base64.StdEncoding.DecodeString(line)
+	if err != nil {
+		return entry, false
+	}
+	entry.Command = string(cmd)
+	return entry, true
 }
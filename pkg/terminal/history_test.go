@@ -3,8 +3,12 @@ package terminal_test
 import (
 	"bytes"
 	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"codeberg.org/ale-cci/connect/pkg/terminal"
 )
@@ -76,3 +80,154 @@ func TestHistorySavesOnFile(t *testing.T) {
 		)
 	}
 }
+
+func TestHistoryEntriesMetadata(t *testing.T) {
+	h := terminal.History{Alias: "sales", Size: 2}
+	h.Add("select 1;")
+	h.Finish(2*time.Second, true)
+	h.Add("select broken;")
+	h.Finish(time.Millisecond, false)
+	h.Add("select 3;")
+
+	if !reflect.DeepEqual(h.Strings, []string{"select broken;", "select 3;"}) {
+		t.Errorf("expected the trimmed history, got %v", h.Strings)
+	}
+
+	histfile := bytes.Buffer{}
+	h.Save(&histfile)
+	newhist := terminal.History{}
+	newhist.Load(&histfile)
+
+	if len(newhist.Entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", newhist.Entries)
+	}
+	got := newhist.Entries[0]
+	if got.Command != "select broken;" || got.Alias != "sales" || got.Duration != time.Millisecond || got.Success || got.Time.IsZero() {
+		t.Errorf("unexpected entry %+v", got)
+	}
+}
+
+func TestHistoryLoadsBase64Lines(t *testing.T) {
+	// history files written before the entries had metadata
+	histfile := bytes.NewBufferString("c2VsZWN0IDE7\nZmlyc3QKY29tbWFuZA==\nnot base64!\n")
+
+	h := terminal.History{}
+	h.Load(histfile)
+
+	expect := []string{"select 1;", "first\ncommand"}
+	if !reflect.DeepEqual(h.Strings, expect) {
+		t.Errorf("expected %v, got %v", expect, h.Strings)
+	}
+}
+
+func TestHistorySaveFileKeepsSimultaneousSessions(t *testing.T) {
+	histfile := filepath.Join(t.TempDir(), "history", "sales.jsonl")
+
+	first := terminal.History{}
+	second := terminal.History{}
+	first.Add("select 1;")
+	second.Add("select 2;")
+	first.Add("select 3;")
+
+	for _, h := range []*terminal.History{&first, &second, &first} {
+		if err := h.SaveFile(histfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := terminal.History{}
+	fd, err := os.Open(histfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	got.Load(fd)
+
+	expect := []string{"select 1;", "select 2;", "select 3;"}
+	if !reflect.DeepEqual(got.Strings, expect) {
+		t.Errorf("expected %v, got %v", expect, got.Strings)
+	}
+}
+
+func TestHistoryLoadMerges(t *testing.T) {
+	start := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	saved := terminal.HistoryEntry{Command: "select 1;", Time: start}
+	other := terminal.HistoryEntry{Command: "select 2;", Time: start.Add(time.Minute)}
+	pending := terminal.HistoryEntry{Command: "select 3;", Time: start.Add(2 * time.Minute)}
+
+	h := terminal.History{Size: 3}
+	h.AddEntry(saved)
+	h.Save(&bytes.Buffer{})
+	h.AddEntry(pending)
+
+	histfile := bytes.Buffer{}
+	source := terminal.History{}
+	source.AddEntry(saved)
+	source.AddEntry(other)
+	source.AddEntry(terminal.HistoryEntry{Command: "select 0;", Time: start.Add(-time.Minute)})
+	source.Save(&histfile)
+	h.Load(&histfile)
+
+	expect := []string{"select 1;", "select 2;", "select 3;"}
+	if !reflect.DeepEqual(h.Strings, expect) {
+		t.Errorf("expected %v, got %v", expect, h.Strings)
+	}
+
+	// only the entry added in memory is left to save
+	unsaved := bytes.Buffer{}
+	h.Save(&unsaved)
+	if got := strings.Count(unsaved.String(), "\n"); got != 1 || !strings.Contains(unsaved.String(), "select 3;") {
+		t.Errorf("expected only the pending entry to be saved, got %q", unsaved.String())
+	}
+}
+
+func TestHistorySaveFileCompacts(t *testing.T) {
+	histfile := filepath.Join(t.TempDir(), "sales.jsonl")
+
+	h := terminal.History{Size: 2}
+	for i := range 5 {
+		h.Add(fmt.Sprintf("select %d;", i))
+		if err := h.SaveFile(histfile); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content, err := os.ReadFile(histfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(content), "\n"); lines > 4 {
+		t.Errorf("expected the file to be compacted, got %d lines", lines)
+	}
+	if !strings.Contains(string(content), "select 4;") {
+		t.Errorf("expected the last command to be kept, got %q", content)
+	}
+}
+
+func TestHistoryFuzzySearch(t *testing.T) {
+	h := terminal.History{}
+	h.Add("select * from sales;")
+	h.Add("show tables;")
+	h.Add("select 1;")
+	h.Add("show tables;")
+
+	tt := []struct {
+		pattern string
+		expect  []string
+	}{
+		{pattern: "", expect: []string{"show tables;", "select 1;", "select * from sales;"}},
+		{pattern: "tab", expect: []string{"show tables;"}},
+		{pattern: "sales", expect: []string{"select * from sales;", "show tables;"}},
+		{pattern: "xyz", expect: []string{}},
+	}
+
+	for _, tc := range tt {
+		got := []string{}
+		for _, match := range h.FuzzySearch(tc.pattern) {
+			got = append(got, match.Command)
+		}
+		if !reflect.DeepEqual(got, tc.expect) {
+			t.Errorf("FuzzySearch(%q): expected %v, got %v", tc.pattern, tc.expect, got)
+		}
+	}
+}
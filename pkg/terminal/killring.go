@@ -0,0 +1,101 @@
+package terminal
+
+import (
+	"slices"
+	"strings"
+)
+
+// killRingSize is the number of killed texts kept for Ctrl+Y and Alt+Y
+const killRingSize = 16
+
+// kill saves the deleted text in the kill ring. Kills following each other are joined,
+// before the previous text when deleting backwards, as in Emacs.
+func (t *Terminal) kill(text []rune, backward bool) {
+	if len(text) == 0 {
+		return
+	}
+
+	if t.lastAction == actionKill && len(t.killRing) > 0 {
+		last := &t.killRing[len(t.killRing)-1]
+		if backward {
+			*last = append(slices.Clone(text), *last...)
+		} else {
+			*last = append(*last, text...)
+		}
+	} else {
+		t.killRing = append(t.killRing, slices.Clone(text))
+		if len(t.killRing) > killRingSize {
+			t.killRing = t.killRing[1:]
+		}
+	}
+	t.action = actionKill
+}
+
+// killLine kills from the cursor to the end of the row, or the newline when already there, Ctrl+K
+func (t *Terminal) killLine() {
+	t.clearCmd()
+	row := t.display[t.pos.row]
+	col := min(t.pos.col, len(row))
+
+	if col < len(row) {
+		t.kill(row[col:], false)
+		t.display[t.pos.row] = slices.Clone(row[:col])
+	} else if t.pos.row < len(t.display)-1 {
+		t.kill([]rune{'\n'}, false)
+		t.display[t.pos.row] = append(slices.Clone(row), t.display[t.pos.row+1]...)
+		t.display = slices.Delete(t.display, t.pos.row+1, t.pos.row+2)
+	}
+	t.pos.col = col
+	t.drawCmd()
+}
+
+// killLineBackward kills from the start of the row to the cursor, Ctrl+U
+func (t *Terminal) killLineBackward() {
+	t.clearCmd()
+	row := t.display[t.pos.row]
+	col := min(t.pos.col, len(row))
+
+	t.kill(row[:col], true)
+	t.display[t.pos.row] = slices.Clone(row[col:])
+	t.pos.col = 0
+	t.drawCmd()
+}
+
+// yank inserts the last killed text at the cursor, Ctrl+Y
+func (t *Terminal) yank() {
+	if len(t.killRing) == 0 {
+		return
+	}
+	t.yankIndex = len(t.killRing) - 1
+	t.yankStart = t.offset()
+	t.replaceText(t.yankStart, t.yankStart, t.killRing[t.yankIndex])
+	t.action = actionYank
+}
+
+// yankPop replaces the text just yanked with the one killed before it, Alt+Y
+func (t *Terminal) yankPop() {
+	if t.lastAction != actionYank || len(t.killRing) == 0 {
+		return
+	}
+	end := t.yankStart + len(t.killRing[t.yankIndex])
+	t.yankIndex = (t.yankIndex + len(t.killRing) - 1) % len(t.killRing)
+	t.replaceText(t.yankStart, end, t.killRing[t.yankIndex])
+	t.action = actionYank
+}
+
+// replaceText replaces the runes of the command between the offsets start and end with text,
+// leaving the cursor after it
+func (t *Terminal) replaceText(start, end int, text []rune) {
+	cmd := []rune(t.command())
+	replaced := slices.Concat(cmd[:start], text, cmd[end:])
+
+	t.clearCmd()
+	t.loadCmd(string(replaced))
+
+	// move the cursor at start+len(text), counting the newlines
+	cursor := start + len(text)
+	before := string(replaced[:cursor])
+	t.pos.row = strings.Count(before, "\n")
+	t.pos.col = len([]rune(before[strings.LastIndex(before, "\n")+1:]))
+	t.drawCmd()
+}
@@ -0,0 +1,56 @@
+package terminal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ReadPassword prompts on the controlling terminal and reads a line without echoing it.
+// The terminal is used directly so that stdin and stdout stay free for piped input or the MCP transport.
+func ReadPassword(prompt string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal available to read the passphrase: %w", err)
+	}
+	defer tty.Close()
+
+	fd := int(tty.Fd())
+	oldState, err := MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer Restore(fd, oldState)
+
+	tty.WriteString(prompt)
+	defer tty.WriteString("\r\n")
+
+	return readSecret(bufio.NewReader(tty))
+}
+
+// readSecret reads runes until Enter, handling backspace and aborting on Ctrl+C or Ctrl+D
+func readSecret(r *bufio.Reader) (string, error) {
+	secret := []rune{}
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch c {
+		case KEY_ENTER, KEY_NL:
+			return string(secret), nil
+		case CTRL_C, CTRL_D:
+			return "", io.EOF
+		case KEY_BACKSPACE, '\b':
+			if len(secret) > 0 {
+				secret = secret[:len(secret)-1]
+			}
+		default:
+			if isPrintable(c) {
+				secret = append(secret, c)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package terminal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrPickerCancelled is returned when the picker is closed without choosing an item
+var ErrPickerCancelled = errors.New("selection cancelled")
+
+// PickerItem is an entry of the picker, Label is displayed and matched against the query
+type PickerItem struct {
+	Value string
+	Label string
+}
+
+// Picker is a fuzzy finder drawn below the prompt, filtering the items as the user types
+type Picker struct {
+	Prompt string
+	Items  []PickerItem
+
+	// Height is the number of visible items, 10 when zero. Labels are cut at Width when set.
+	Height int
+	Width  int
+
+	query    []rune
+	matches  []PickerItem
+	selected int
+	offset   int
+	buffer   []byte
+}
+
+// Pick lets the user choose an item on the controlling terminal and returns its Value.
+// The terminal is used directly so that stdin and stdout stay free for piped input.
+func Pick(prompt string, items []PickerItem) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("no terminal available to pick from: %w", err)
+	}
+	defer tty.Close()
+
+	fd := int(tty.Fd())
+	oldState, err := MakeRaw(fd)
+	if err != nil {
+		return "", err
+	}
+	defer Restore(fd, oldState)
+
+	picker := Picker{Prompt: prompt, Items: items}
+	if size, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ); err == nil {
+		picker.Width = int(size.Col)
+		picker.Height = min(10, int(size.Row)-2)
+	}
+	return picker.Run(bufio.NewReader(tty), tty)
+}
+
+// Run reads keys until an item is chosen with Enter, or the picker is closed with Ctrl+C, Ctrl+D or Esc
+func (p *Picker) Run(r *bufio.Reader, w io.Writer) (string, error) {
+	if p.Height <= 0 {
+		p.Height = 10
+	}
+	p.filter()
+
+	for {
+		p.draw()
+		w.Write(p.buffer)
+		p.buffer = []byte{}
+
+		c, _, err := r.ReadRune()
+		if err != nil {
+			p.clear(w)
+			return "", err
+		}
+
+		switch c {
+		case KEY_ENTER, KEY_NL:
+			if len(p.matches) > 0 {
+				p.clear(w)
+				return p.matches[p.selected].Value, nil
+			}
+
+		case CTRL_C, CTRL_D:
+			p.clear(w)
+			return "", ErrPickerCancelled
+
+		case KEY_ESCAPE:
+			// a lone escape closes the picker, otherwise it starts an arrow key sequence
+			if r.Buffered() == 0 {
+				p.clear(w)
+				return "", ErrPickerCancelled
+			}
+			switch readEscape(r) {
+			case 'A':
+				p.move(-1)
+			case 'B':
+				p.move(1)
+			}
+
+		case CTRL_P:
+			p.move(-1)
+
+		case CTRL_N:
+			p.move(1)
+
+		case KEY_BACKSPACE, '\b':
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+
+		case CTRL_W:
+			end := len(p.query)
+			for end > 0 && unicode.IsSpace(p.query[end-1]) {
+				end -= 1
+			}
+			for end > 0 && !unicode.IsSpace(p.query[end-1]) {
+				end -= 1
+			}
+			p.query = p.query[:end]
+			p.filter()
+
+		case CTRL_U:
+			p.query = []rune{}
+			p.filter()
+
+		default:
+			if isPrintable(c) {
+				p.query = append(p.query, c)
+				p.filter()
+			}
+		}
+	}
+}
+
+// readEscape consumes a CSI or SS3 sequence after the escape byte and returns its final byte
+func readEscape(r *bufio.Reader) byte {
+	b, err := r.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return 0
+	}
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0
+		}
+		if b >= 0x40 && b <= 0x7e {
+			return b
+		}
+	}
+}
+
+func (p *Picker) filter() {
+	p.matches = FilterItems(p.Items, string(p.query))
+	p.selected = 0
+	p.offset = 0
+}
+
+func (p *Picker) move(delta int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.selected = min(max(p.selected+delta, 0), len(p.matches)-1)
+	if p.selected < p.offset {
+		p.offset = p.selected
+	}
+	if p.selected >= p.offset+p.Height {
+		p.offset = p.selected - p.Height + 1
+	}
+}
+
+// draw writes the prompt, a counter and the visible items, leaving the cursor after the query
+func (p *Picker) draw() {
+	p.buffer = append(p.buffer, "\r\x1b[0J"...)
+	p.buffer = append(p.buffer, p.Prompt...)
+	p.buffer = append(p.buffer, string(p.query)...)
+	p.buffer = fmt.Appendf(p.buffer, "\r\n  %d/%d", len(p.matches), len(p.Items))
+
+	end := min(p.offset+p.Height, len(p.matches))
+	for i := p.offset; i < end; i++ {
+		label := []rune(p.matches[i].Label)
+		if p.Width > 2 && len(label) > p.Width-2 {
+			label = label[:p.Width-2]
+		}
+
+		if i == p.selected {
+			p.buffer = fmt.Appendf(p.buffer, "\r\n\x1b[7m> %s\x1b[0m", string(label))
+		} else {
+			p.buffer = fmt.Appendf(p.buffer, "\r\n  %s", string(label))
+		}
+	}
+
+	p.buffer = fmt.Appendf(p.buffer, "\x1b[%dA\x1b[%dG", end-p.offset+1, len([]rune(p.Prompt))+len(p.query)+1)
+}
+
+// clear removes the picker from the screen
+func (p *Picker) clear(w io.Writer) {
+	w.Write([]byte("\r\x1b[0J"))
+}
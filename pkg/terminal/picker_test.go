@@ -0,0 +1,120 @@
+package terminal_test
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	tt := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{pattern: "", s: "sales", match: true},
+		{pattern: "sp", s: "sales_prod", match: true},
+		{pattern: "SP", s: "sales_prod", match: true},
+		{pattern: "ps", s: "sales_prod", match: false},
+		{pattern: "salesx", s: "sales", match: false},
+	}
+
+	for _, tc := range tt {
+		_, match := terminal.FuzzyScore(tc.pattern, tc.s)
+		if match != tc.match {
+			t.Errorf("FuzzyScore(%q, %q): expected match %v, got %v", tc.pattern, tc.s, tc.match, match)
+		}
+	}
+
+	prefix, _ := terminal.FuzzyScore("sp", "sales_prod")
+	scattered, _ := terminal.FuzzyScore("sp", "shop")
+	if prefix <= scattered {
+		t.Errorf("expected word starts to score higher, got %d <= %d", prefix, scattered)
+	}
+}
+
+func TestFilterItems(t *testing.T) {
+	items := []terminal.PickerItem{
+		{Value: "shop", Label: "shop"},
+		{Value: "sales_prod", Label: "sales_prod"},
+		{Value: "billing", Label: "billing"},
+	}
+
+	tt := []struct {
+		query  string
+		expect []string
+	}{
+		{query: "", expect: []string{"shop", "sales_prod", "billing"}},
+		{query: "sp", expect: []string{"sales_prod", "shop"}},
+		{query: "bil", expect: []string{"billing"}},
+		{query: "xyz", expect: []string{}},
+	}
+
+	for _, tc := range tt {
+		got := []string{}
+		for _, item := range terminal.FilterItems(items, tc.query) {
+			got = append(got, item.Value)
+		}
+		if !reflect.DeepEqual(tc.expect, got) {
+			t.Errorf("query %q: expected %v, got %v", tc.query, tc.expect, got)
+		}
+	}
+}
+
+func TestPickerRun(t *testing.T) {
+	items := []terminal.PickerItem{
+		{Value: "sales_prod", Label: "sales_prod  10.0.1.5  sales"},
+		{Value: "sales_stg", Label: "sales_stg   10.0.2.5  sales"},
+		{Value: "billing", Label: "billing     10.0.1.6  billing"},
+	}
+
+	tt := []struct {
+		input  string
+		expect string
+		err    error
+	}{
+		{input: "\r", expect: "sales_prod"},
+		{input: "bil\r", expect: "billing"},
+		{input: "\x1b[B\x1b[B\r", expect: "billing"},
+		{input: "\x0e\x0e\x0e\x10\r", expect: "sales_stg"},
+		{input: "stg\x7f\x7f\x7f\r", expect: "sales_prod"},
+		{input: "xyz\r\x15bil\r", expect: "billing"},
+		{input: "sales\x03", err: terminal.ErrPickerCancelled},
+	}
+
+	for _, tc := range tt {
+		var output bytes.Buffer
+		picker := terminal.Picker{Prompt: "connect> ", Items: items}
+		got, err := picker.Run(bufio.NewReader(strings.NewReader(tc.input)), &output)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("input %q: expected error %v, got %v", tc.input, tc.err, err)
+		}
+		if got != tc.expect {
+			t.Errorf("input %q: expected %q, got %q", tc.input, tc.expect, got)
+		}
+	}
+}
+
+func TestPickerDraw(t *testing.T) {
+	var output bytes.Buffer
+	picker := terminal.Picker{
+		Prompt: "> ",
+		Items:  []terminal.PickerItem{{Value: "a", Label: "alpha"}, {Value: "b", Label: "beta"}},
+		Height: 1,
+		Width:  5,
+	}
+	picker.Run(bufio.NewReader(strings.NewReader("\x1b[B")), &output)
+
+	got := output.String()
+	expect := "\r\x1b[0J> \r\n  2/2\r\n\x1b[7m> alp\x1b[0m\x1b[2A\x1b[3G" +
+		"\r\x1b[0J> \r\n  2/2\r\n\x1b[7m> bet\x1b[0m\x1b[2A\x1b[3G" +
+		"\r\x1b[0J"
+	if got != expect {
+		t.Errorf("expected output %q, got %q", expect, got)
+	}
+}
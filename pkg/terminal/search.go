@@ -0,0 +1,207 @@
+package terminal
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// searchHeight is the number of matches listed below the reverse search prompt
+const searchHeight = 5
+
+// search is the state of a reverse search started with Ctrl+R
+type search struct {
+	query    []rune
+	matches  []SearchMatch
+	selected int
+	offset   int
+}
+
+func (s *search) move(delta int) {
+	if len(s.matches) == 0 {
+		return
+	}
+	s.selected = min(max(s.selected+delta, 0), len(s.matches)-1)
+	if s.selected < s.offset {
+		s.offset = s.selected
+	}
+	if s.selected >= s.offset+searchHeight {
+		s.offset = s.selected - searchHeight + 1
+	}
+}
+
+// reverseSearch looks for the typed runes in the history with a fuzzy search, showing the selected
+// match as the command and the best matches below the search prompt. Ctrl+R and Ctrl+S (or the arrows)
+// move through the matches, Enter loads the selected one for editing, while Esc, Ctrl+C and Ctrl+G
+// restore the command typed before the search.
+func (t *Terminal) reverseSearch() error {
+	original := t.command()
+	originalPos := t.pos
+
+	s := search{matches: t.History.FuzzySearch("")}
+	width := t.width()
+
+	// the search is drawn from the prompt row, rowsAbove tracks where the cursor is left
+	t.clearCmd()
+	rowsAbove := 0
+
+	finish := func(accept bool) {
+		if rowsAbove > 0 {
+			t.buffer = fmt.Appendf(t.buffer, "\x1b[%dA", rowsAbove)
+		}
+		t.buffer = fmt.Appendf(t.buffer, "\x1b[%dG\x1b[0J", DisplayWidth(t.Prompt)+1)
+
+		if !accept || len(s.matches) == 0 {
+			t.loadCmd(original)
+			t.pos = originalPos
+		}
+		t.drawCmd()
+	}
+
+	for {
+		if len(s.matches) > 0 {
+			t.loadCmd(s.matches[s.selected].Command)
+		} else {
+			t.loadCmd(original)
+		}
+		rowsAbove = t.drawSearch(&s, rowsAbove, width)
+		t.flush()
+
+		r, _, err := t.Input.ReadRune()
+		if err != nil {
+			return err
+		}
+
+		switch r {
+		case KEY_ENTER, KEY_NL:
+			finish(true)
+			return nil
+
+		case CTRL_C, CTRL_G:
+			finish(false)
+			return nil
+
+		case CTRL_D:
+			finish(false)
+			return io.EOF
+
+		case KEY_ESCAPE:
+			// a lone escape cancels the search, otherwise it starts an arrow key sequence.
+			// Nothing buffered means the key was pressed alone, peeking would wait for the next one.
+			if t.Input.Buffered() == 0 {
+				finish(false)
+				return nil
+			}
+			if next, err := t.Input.Peek(1); err != nil || (next[0] != '[' && next[0] != 'O') {
+				finish(false)
+				return nil
+			}
+			switch readEscape(&t.Input) {
+			case 'A':
+				s.move(-1)
+			case 'B':
+				s.move(1)
+			}
+
+		case CTRL_R:
+			s.move(1)
+
+		case CTRL_S:
+			s.move(-1)
+
+		case KEY_BACKSPACE, '\b':
+			if len(s.query) > 0 {
+				s.query = s.query[:len(s.query)-1]
+				s.matches = t.History.FuzzySearch(string(s.query))
+				s.selected, s.offset = 0, 0
+			}
+
+		case CTRL_U:
+			s.query = []rune{}
+			s.matches = t.History.FuzzySearch("")
+			s.selected, s.offset = 0, 0
+
+		default:
+			if isPrintable(r) {
+				s.query = append(s.query, r)
+				s.matches = t.History.FuzzySearch(string(s.query))
+				s.selected, s.offset = 0, 0
+			}
+		}
+	}
+}
+
+// drawSearch redraws the command, the search prompt and the visible matches, leaving the cursor
+// after the query. Returns the number of rows between the prompt and the cursor.
+func (t *Terminal) drawSearch(s *search, rowsAbove int, width int) int {
+	if rowsAbove > 0 {
+		t.buffer = fmt.Appendf(t.buffer, "\x1b[%dA", rowsAbove)
+	}
+	t.buffer = fmt.Appendf(t.buffer, "\x1b[%dG\x1b[0J", DisplayWidth(t.Prompt)+1)
+
+	for i, row := range t.display {
+		if i > 0 {
+			t.buffer = append(t.buffer, '\r', '\n')
+		}
+		t.buffer = append(t.buffer, string(DisplayString(row, t.TabSize))...)
+	}
+
+	label := "reverse-i-search"
+	if len(s.matches) == 0 {
+		label = "failing reverse-i-search"
+	}
+	prompt := fmt.Sprintf("(%s) ", label)
+	t.buffer = fmt.Appendf(t.buffer, "\r\n%s%s", prompt, string(s.query))
+
+	end := min(s.offset+searchHeight, len(s.matches))
+	for i := s.offset; i < end; i++ {
+		t.buffer = append(t.buffer, '\r', '\n')
+		t.buffer = appendMatch(t.buffer, s.matches[i], i == s.selected, width)
+	}
+
+	if end > s.offset {
+		t.buffer = fmt.Appendf(t.buffer, "\x1b[%dA", end-s.offset)
+	}
+	t.buffer = fmt.Appendf(t.buffer, "\x1b[%dG", len(prompt)+len(s.query)+1)
+	return len(t.display)
+}
+
+// appendMatch writes the command on a single line, underlining the matched runes
+func appendMatch(buffer []byte, match SearchMatch, selected bool, width int) []byte {
+	line := []rune(strings.NewReplacer("\n", " ", "\t", " ").Replace(match.Command))
+	if width > 2 && len(line) > width-2 {
+		line = line[:width-2]
+	}
+
+	if selected {
+		buffer = append(buffer, "\x1b[7m> "...)
+	} else {
+		buffer = append(buffer, "  "...)
+	}
+	for i, r := range line {
+		if slices.Contains(match.Positions, i) {
+			buffer = fmt.Appendf(buffer, "\x1b[1;4m%c\x1b[22;24m", r)
+		} else {
+			buffer = append(buffer, string(r)...)
+		}
+	}
+	if selected {
+		buffer = append(buffer, "\x1b[0m"...)
+	}
+	return buffer
+}
+
+// width of the terminal, zero when unknown
+func (t *Terminal) width() int {
+	if t.State == nil {
+		return 0
+	}
+	size, err := unix.IoctlGetWinsize(t.Fd, unix.TIOCGWINSZ)
+	if err != nil {
+		return 0
+	}
+	return int(size.Col)
+}
@@ -28,6 +28,24 @@ type Terminal struct {
 
 	History History
 
+	// Completer is asked for completions on Tab, a tab is inserted when nil
+	Completer Completer
+
+	// Highlighter colors the command while it is typed, when set
+	Highlighter Highlighter
+
+	// command loaded by the next ReadCmd, see Preload
+	preload string
+
+	killRing  [][]rune
+	yankIndex int
+	yankStart int
+
+	undoStack  []editState
+	redoStack  []editState
+	action     editAction
+	lastAction editAction
+
 	Fd    int
 	State *State
 }
@@ -41,11 +59,19 @@ const (
 
 	CTRL_A = 'a' & 0x1f
 	CTRL_E = 'e' & 0x1f
+	CTRL_G = 'g' & 0x1f
 	CTRL_P = 'p' & 0x1f
 	CTRL_N = 'n' & 0x1f
 	CTRL_R = 'r' & 0x1f
 	CTRL_S = 's' & 0x1f
 	CTRL_Q = 'q' & 0x1f
+	CTRL_U = 'u' & 0x1f
+	CTRL_X = 'x' & 0x1f
+	CTRL_K = 'k' & 0x1f
+	CTRL_Y = 'y' & 0x1f
+
+	// Ctrl+_, sent also by Ctrl+/ and Ctrl+7
+	CTRL_UNDERSCORE = 0x1f
 
 	KEY_NL        = 10
 	KEY_ENTER     = 13
@@ -60,10 +86,25 @@ func (t *Terminal) ReadCmd() (cmd string, err error) {
 	t.Output.Write([]byte(t.Prompt))
 	t.buffer = []byte{}
 	t.History.ResetCounter()
+	t.undoStack = nil
+	t.redoStack = nil
+	t.action = actionOther
 
 	done := false
+	if t.preload != "" {
+		t.loadCmd(t.preload)
+		t.preload = ""
+		t.drawCmd()
+		done = t.isCommandComplete()
+		if done {
+			t.buffer = append(t.buffer, '\r', '\n')
+		}
+	}
 
 	for !done {
+		before := t.snapshot()
+		t.lastAction, t.action = t.action, actionOther
+
 		b, err := t.Input.Peek(1)
 
 		if err != nil {
@@ -105,70 +146,24 @@ func (t *Terminal) ReadCmd() (cmd string, err error) {
 			return "", io.EOF
 
 		case CTRL_R:
-			// start reverse search
 			t.Input.ReadByte()
-			// start searching
-			toSearch := []rune{}
-
-			clearSearch := func() {
-				t.buffer = append(t.buffer, []byte("\x1b[1G\x1b[2K\x1b[A")...)
+			if err := t.reverseSearch(); err != nil {
+				return "", err
 			}
 
-			for {
-				// clearline & drawsearch
-				t.clearCmd()
-				t.drawCmd()
-
-				// draw search
-				t.buffer = fmt.Appendf(t.buffer, "\r\n\x1b[2Ksearch: %s", string(toSearch))
-				// draw search after
-				t.Output.Write(t.buffer)
-				t.buffer = []byte{}
-				b, err := t.Input.ReadByte()
-				if err != nil {
-					return "", err
-				}
-
-				r := rune(b)
-				if b == KEY_BACKSPACE {
-					if len(toSearch) > 0 {
-						toSearch = toSearch[:len(toSearch)-1]
-
-						t.History.ResetCounter()
-						cmd, err := t.History.Search(string(toSearch))
-						if err == nil {
-							t.loadCmd(cmd)
-						}
-					}
-
-				} else if b == KEY_ENTER {
-					break
-				} else if b == CTRL_C {
-					break
-				} else if b == CTRL_D {
-					return "", io.EOF
-				} else if b == CTRL_R {
-					cmd, err := t.History.Search(string(toSearch))
-					if err != nil {
-						t.loadCmd(cmd)
-					}
-				} else if isPrintable(r) {
-					toSearch = append(toSearch, r)
-
-					t.History.ResetCounter()
-					cmd, err := t.History.Search(string(toSearch))
-					if err == nil {
-						t.loadCmd(cmd)
-					}
+		case CTRL_X:
+			t.Input.ReadByte()
+			next, err := t.Input.ReadByte()
+			if err != nil {
+				return "", err
+			}
 
-				}
-				clearSearch()
+			// Ctrl+X Ctrl+E edits the command in $EDITOR, a complete command is run right away
+			if next == CTRL_E && t.editCmd() {
+				t.buffer = append(t.buffer, '\r', '\n')
+				done = true
 			}
-			clearSearch()
-			t.clearCmd()
-			t.drawCmd()
-			t.Output.Write(t.buffer)
-			t.buffer = []byte{}
+
 		case CTRL_L:
 			t.Input.ReadByte()
 
@@ -181,6 +176,7 @@ func (t *Terminal) ReadCmd() (cmd string, err error) {
 			t.Input.ReadByte()
 
 			r := t.delRune()
+			killed := []rune{}
 			isWord := func(r rune) bool {
 				return unicode.IsLetter(r) || r == '_' || unicode.IsDigit(r)
 			}
@@ -188,12 +184,30 @@ func (t *Terminal) ReadCmd() (cmd string, err error) {
 			wordDeleted := isWord(r)
 
 			for r != '\x00' {
+				killed = append([]rune{r}, killed...)
 				if !isWord(t.prevRune()) && wordDeleted {
 					break
 				}
 				r = t.delRune()
 				wordDeleted = wordDeleted || !unicode.IsSpace(r)
 			}
+			t.kill(killed, true)
+
+		case CTRL_K:
+			t.Input.ReadByte()
+			t.killLine()
+
+		case CTRL_U:
+			t.Input.ReadByte()
+			t.killLineBackward()
+
+		case CTRL_Y:
+			t.Input.ReadByte()
+			t.yank()
+
+		case CTRL_UNDERSCORE:
+			t.Input.ReadByte()
+			t.undo()
 
 		case CTRL_A:
 			t.Input.ReadByte()
@@ -235,20 +249,26 @@ func (t *Terminal) ReadCmd() (cmd string, err error) {
 			_, err = t.Input.ReadByte()
 			t.delRune()
 
+		case '\t':
+			t.Input.ReadByte()
+			t.complete()
+
 		default:
 			r, _, err := t.Input.ReadRune()
 			if err != nil {
 				return "", err
 			}
 
-			if isPrintable(r) || r == '\t' {
+			if isPrintable(r) {
 				t.clearCmd()
 				t.insertRune(r)
 				t.drawCmd()
+				t.action = actionInsert
 			} else {
 				fmt.Printf("<%d>", r)
 			}
 		}
+		t.recordUndo(before)
 		t.flush()
 	}
 	t.flush()
@@ -269,11 +289,9 @@ func (t *Terminal) insertRune(r rune) {
 
 	if r == '\n' {
 		t.display = append(
-			append(
-				t.display[:t.pos.row],
-				line,
-				after,
-			),
+			t.display[:t.pos.row],
+			line,
+			after,
 		)
 
 		t.pos.row += 1
@@ -300,17 +318,28 @@ func (t *Terminal) clearCmd() {
 	if t.pos.row >= 1 {
 		t.buffer = fmt.Appendf(t.buffer, "\x1b[%dA", t.pos.row)
 	}
-	t.buffer = fmt.Appendf(t.buffer, "\x1b[%dG\x1b[0J", len(t.Prompt)+1)
+	t.buffer = fmt.Appendf(t.buffer, "\x1b[%dG\x1b[0J", DisplayWidth(t.Prompt)+1)
 }
 
 // expects the prompt to be at 0:0
 func (t *Terminal) drawCmd() {
+	var colors []string
+	if t.Highlighter != nil {
+		colors = t.Highlighter.Highlight([]rune(t.command()))
+	}
+
+	offset := 0
 	for i, row := range t.display {
 		if i > 0 {
 			t.buffer = append(t.buffer, '\r', '\n')
 		}
-		output := DisplayString(row, t.TabSize)
-		t.buffer = append(t.buffer, []byte(string(output))...)
+		if colors != nil {
+			t.buffer = appendHighlighted(t.buffer, row, colors[offset:offset+len(row)], t.TabSize)
+		} else {
+			output := DisplayString(row, t.TabSize)
+			t.buffer = append(t.buffer, []byte(string(output))...)
+		}
+		offset += len(row) + 1
 	}
 
 	currentRow := len(t.display) - 1
@@ -476,9 +505,17 @@ func (t *Terminal) parseEscape() error {
 				toDelete += 1
 			}
 
+			killed := []rune{}
 			for i := 0; i < toDelete; i += 1 {
-				t.delRune()
+				killed = append([]rune{t.delRune()}, killed...)
 			}
+			t.kill(killed, true)
+
+		case 'y':
+			t.yankPop()
+
+		case '_':
+			t.redo()
 
 		default:
 			// alt - key combination
@@ -512,7 +549,7 @@ func (t *Terminal) column() int {
 	cursorX := len(DisplayString(t.display[t.pos.row][:t.pos.col], t.TabSize)) + 1
 	// cursorX := CursorPos(t.display[t.pos.row], t.pos.col)
 	if t.pos.row == 0 {
-		cursorX += len(t.Prompt) // 2 "> "
+		cursorX += DisplayWidth(t.Prompt) // 2 "> "
 	}
 	return cursorX
 }
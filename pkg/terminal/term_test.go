@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"codeberg.org/ale-cci/connect/pkg/terminal"
@@ -233,3 +235,422 @@ func TestIsTerminal(t *testing.T) {
 		t.Errorf("expected IsTerminal(0) to be false in non-interactive test environment")
 	}
 }
+
+func TestReverseSearch(t *testing.T) {
+	tt := []struct {
+		history []string
+		input   string
+		expect  string
+	}{
+		{
+			// fuzzy match
+			history: []string{"show tables;", "select 1;"},
+			input:   "\x12shtb\r\r",
+			expect:  "show tables;",
+		},
+		{
+			// ctrl-r moves to the next match, ctrl-s back
+			history: []string{"select 1;", "select 2;", "select 3;"},
+			input:   "\x12sel\x12\x12\x13\r\r",
+			expect:  "select 2;",
+		},
+		{
+			// arrow down moves to the next match
+			history: []string{"select 1;", "select 2;"},
+			input:   "\x12sel\x1b[B\r\r",
+			expect:  "select 1;",
+		},
+		{
+			// esc restores the command typed before the search
+			history: []string{"select 1;"},
+			input:   "show\x12sel\x1b tables;\r",
+			expect:  "show tables;",
+		},
+		{
+			// ctrl-c restores the command typed before the search
+			history: []string{"select 1;"},
+			input:   "show\x12sel\x03 tables;\r",
+			expect:  "show tables;",
+		},
+		{
+			// duplicate commands are listed once
+			history: []string{"select 1;", "select 2;", "select 1;"},
+			input:   "\x12sel\x12\r\r",
+			expect:  "select 2;",
+		},
+	}
+
+	for idx, tc := range tt {
+		output := bytes.Buffer{}
+		term := terminal.Terminal{
+			Input:  *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output: &output,
+			Prompt: "> ",
+		}
+		for _, h := range tc.history {
+			term.History.Add(h)
+		}
+
+		cmd, err := term.ReadCmd()
+		if err != nil {
+			t.Errorf("TestReverseSearch[%d]: expected nil error, got %v", idx, err)
+			continue
+		}
+		if cmd != tc.expect {
+			t.Errorf("TestReverseSearch[%d]: expected %q, got %q", idx, tc.expect, cmd)
+		}
+	}
+}
+
+// chunkReader returns one chunk per read, as a terminal returns the keys pressed so far
+type chunkReader struct {
+	chunks [][]byte
+	before func()
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	if r.before != nil {
+		r.before()
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestReverseSearchLoneEscape(t *testing.T) {
+	output := bytes.Buffer{}
+	reads := 0
+	input := &chunkReader{
+		chunks: [][]byte{[]byte("show\x12sel\x1b"), []byte(" tables;\r")},
+	}
+	// the escape ends the first read: the search must be closed without waiting for more input
+	input.before = func() {
+		reads += 1
+		drawn := output.String()
+		if reads == 2 && strings.Contains(drawn[strings.LastIndex(drawn, "\x1b[0J"):], "reverse-i-search") {
+			t.Errorf("expected the search to be closed before reading the next key")
+		}
+	}
+
+	term := terminal.Terminal{
+		Input:  *bufio.NewReader(input),
+		Output: &output,
+		Prompt: "> ",
+	}
+	term.History.Add("select 1;")
+
+	cmd, err := term.ReadCmd()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if cmd != "show tables;" {
+		t.Errorf("expected %q, got %q", "show tables;", cmd)
+	}
+}
+
+func TestReverseSearchHighlightsMatches(t *testing.T) {
+	output := bytes.Buffer{}
+	term := terminal.Terminal{
+		Input:  *bufio.NewReader(bytes.NewBufferString("\x12st\r\r")),
+		Output: &output,
+		Prompt: "> ",
+	}
+	term.History.Add("show tables;")
+
+	if _, err := term.ReadCmd(); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	expect := "\x1b[7m> \x1b[1;4ms\x1b[22;24mhow \x1b[1;4mt\x1b[22;24mables;\x1b[0m"
+	if !strings.Contains(output.String(), expect) {
+		t.Errorf("expected the highlighted match %q in %q", expect, output.String())
+	}
+}
+
+type wordsCompleter []string
+
+func (c wordsCompleter) Complete(line string, pos int) (string, []string) {
+	line = string([]rune(line)[:pos])
+	word := line[strings.LastIndexAny(line, " \t\n")+1:]
+
+	candidates := []string{}
+	for _, candidate := range c {
+		if word != "" && strings.HasPrefix(strings.ToLower(candidate), strings.ToLower(word)) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return word, candidates
+}
+
+func TestTabCompletion(t *testing.T) {
+	tt := []struct {
+		completer terminal.Completer
+		input     string
+		expect    string
+		listed    bool
+	}{
+		{completer: nil, input: "select\t1;\r", expect: "select\t1;"},
+		{completer: wordsCompleter{"select", "show"}, input: "sel\t 1;\r", expect: "select 1;"},
+		{completer: wordsCompleter{"users", "user_id"}, input: "select us\t;\r", expect: "select user;"},
+		{completer: wordsCompleter{"users", "user_id"}, input: "select user\t;\r", expect: "select user;", listed: true},
+		{completer: wordsCompleter{"users"}, input: "\tselect 1;\r", expect: "\tselect 1;"},
+		{completer: wordsCompleter{"users"}, input: "select  from;\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[Du\t\r", expect: "select users from;"},
+		{completer: wordsCompleter{"Users", "Usage"}, input: "select u\t;\r", expect: "select Us;"},
+		{completer: wordsCompleter{"Orders", "ORDER_ITEMS"}, input: "select o\t;\r", expect: "select Order;"},
+		{completer: wordsCompleter{"Users"}, input: "select u\t;\r", expect: "select Users;"},
+		{completer: wordsCompleter{"users"}, input: "SELECT * FROM USE\t;\r", expect: "SELECT * FROM users;"},
+	}
+
+	for idx, tc := range tt {
+		output := bytes.Buffer{}
+		term := terminal.Terminal{
+			Input:     *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output:    &output,
+			Prompt:    "> ",
+			Completer: tc.completer,
+		}
+
+		cmd, err := term.ReadCmd()
+		if err != nil {
+			t.Errorf("TestTabCompletion[%d]: expected nil error, got %v", idx, err)
+			continue
+		}
+		if cmd != tc.expect {
+			t.Errorf("TestTabCompletion[%d]: expected %q, got %q", idx, tc.expect, cmd)
+		}
+		if listed := strings.Contains(output.String(), "users    user_id"); listed != tc.listed {
+			t.Errorf("TestTabCompletion[%d]: expected candidates listed %v, got %q", idx, tc.listed, output.String())
+		}
+	}
+}
+
+func TestEditCommand(t *testing.T) {
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i -e s/1/2/")
+
+	tt := []struct {
+		history []string
+		input   string
+		expect  string
+	}{
+		{
+			// complete commands are submitted as soon as the editor exits
+			input:  "select 1;\x18\x05",
+			expect: "select 2;",
+		},
+		{
+			// incomplete ones are left to edit
+			input:  "select 1\x18\x05;\r",
+			expect: "select 2;",
+		},
+		{
+			// an empty buffer edits the last command
+			history: []string{"select 1\nfrom t;"},
+			input:   "\x18\x05",
+			expect:  "select 2\nfrom t;",
+		},
+	}
+
+	for idx, tc := range tt {
+		output := bytes.Buffer{}
+		term := terminal.Terminal{
+			Input:  *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output: &output,
+			Prompt: "> ",
+		}
+		for _, h := range tc.history {
+			term.History.Add(h)
+		}
+
+		cmd, err := term.ReadCmd()
+		if err != nil {
+			t.Errorf("TestEditCommand[%d]: expected nil error, got %v", idx, err)
+			continue
+		}
+		if cmd != tc.expect {
+			t.Errorf("TestEditCommand[%d]: expected %q, got %q", idx, tc.expect, cmd)
+		}
+	}
+}
+
+func TestEditTextFailure(t *testing.T) {
+	t.Setenv("VISUAL", "false")
+
+	term := terminal.Terminal{}
+	if _, err := term.EditText("select 1;"); err == nil {
+		t.Errorf("expected an error when the editor fails")
+	}
+}
+
+func TestPreload(t *testing.T) {
+	tt := []struct {
+		preload string
+		input   string
+		expect  string
+	}{
+		{preload: "select 1;", input: "", expect: "select 1;"},
+		{preload: "select 1", input: "0;\r", expect: "select 10;"},
+	}
+
+	for idx, tc := range tt {
+		term := terminal.Terminal{
+			Input:  *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output: &bytes.Buffer{},
+			Prompt: "> ",
+		}
+		term.Preload(tc.preload)
+
+		cmd, err := term.ReadCmd()
+		if err != nil || cmd != tc.expect {
+			t.Errorf("TestPreload[%d]: expected %q, got %q %v", idx, tc.expect, cmd, err)
+		}
+	}
+}
+
+func TestKillRing(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect string
+	}{
+		{
+			// ctrl-k kills until the end of the row, ctrl-y yanks it back
+			input:  "select 1 from t;\x01\x1bf\x1bf\x0b\x05 *\x19\r",
+			expect: "select 1 * from t;",
+		},
+		{
+			// ctrl-u kills until the start of the row
+			input:  "users select *;\x1bb\x1bb\x15\x05\x7f from \x19\x7f;\r",
+			expect: "select * from users;",
+		},
+		{
+			// ctrl-w kills the previous word
+			input:  "from t select 1\x17\x17\x19;\r",
+			expect: "from t select 1;",
+		},
+		{
+			// consecutive kills are joined
+			input:  "select a b\x17\x17\x19\x19;\r",
+			expect: "select a ba b;",
+		},
+		{
+			// alt-y replaces the yanked text with the previous kill
+			input:  "one two\x17\x7f\x17\x19\x1by;\r",
+			expect: "two;",
+		},
+		{
+			// and cycles through the ring
+			input:  "one two\x17\x7f\x17\x19\x1by\x1by;\r",
+			expect: "one;",
+		},
+		{
+			// alt-y without a yank does nothing
+			input:  "one\x17x\x1by;\r",
+			expect: "x;",
+		},
+		{
+			// alt-backspace kills the previous word
+			input:  "select 1 2\x1b\x7f\x1b\x7f\x19 2;\r",
+			expect: "select 1 2 2;",
+		},
+		{
+			// killing the newline joins the rows
+			input:  "a\rb;\x1b[A\x05\x0b\x0b\x19\r",
+			expect: "a\nb;",
+		},
+	}
+
+	for idx, tc := range tt {
+		term := terminal.Terminal{
+			Input:  *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output: &bytes.Buffer{},
+			Prompt: "> ",
+		}
+
+		cmd, err := term.ReadCmd()
+		if err != nil {
+			t.Errorf("TestKillRing[%d]: expected nil error, got %v", idx, err)
+			continue
+		}
+		if cmd != tc.expect {
+			t.Errorf("TestKillRing[%d]: expected %q, got %q", idx, tc.expect, cmd)
+		}
+	}
+}
+
+func TestKillRingAcrossCommands(t *testing.T) {
+	term := terminal.Terminal{
+		Input:  *bufio.NewReader(bytes.NewBufferString("select 1\x15;\r\x19;\r")),
+		Output: &bytes.Buffer{},
+		Prompt: "> ",
+	}
+
+	for _, expect := range []string{";", "select 1;"} {
+		cmd, err := term.ReadCmd()
+		if err != nil || cmd != expect {
+			t.Errorf("expected %q, got %q %v", expect, cmd, err)
+		}
+	}
+}
+
+func TestUndoRedo(t *testing.T) {
+	tt := []struct {
+		input  string
+		expect string
+	}{
+		{
+			// typed text is undone at once
+			input:  "select 1\x1f2;\r",
+			expect: "2;",
+		},
+		{
+			// each deletion is undone on its own
+			input:  "select 12\x7f\x7f\x1f\x1f;\r",
+			expect: "select 12;",
+		},
+		{
+			// redo applies the undone change again
+			input:  "select 12\x7f\x1f\x1b_;\r",
+			expect: "select 1;",
+		},
+		{
+			// undo across rows
+			input:  "select\r1\x7f\x7f\x1f\x1f;\r",
+			expect: "select\n1;",
+		},
+		{
+			// kills and yanks are undone
+			input:  "select 1\x17\x19\x19\x1f\x1f\x1f;\r",
+			expect: "select 1;",
+		},
+		{
+			// a new change clears the redo
+			input:  "select 1\x7f\x1f2\x1b_;\r",
+			expect: "select 12;",
+		},
+		{
+			// nothing to undo
+			input:  "\x1f\x1b_select 1;\r",
+			expect: "select 1;",
+		},
+	}
+
+	for idx, tc := range tt {
+		term := terminal.Terminal{
+			Input:  *bufio.NewReader(bytes.NewBufferString(tc.input)),
+			Output: &bytes.Buffer{},
+			Prompt: "> ",
+		}
+
+		cmd, err := term.ReadCmd()
+		if err != nil {
+			t.Errorf("TestUndoRedo[%d]: expected nil error, got %v", idx, err)
+			continue
+		}
+		if cmd != tc.expect {
+			t.Errorf("TestUndoRedo[%d]: expected %q, got %q", idx, tc.expect, cmd)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package terminal
+
+import "slices"
+
+// editAction is the kind of the last key handled by ReadCmd, consecutive insertions are undone
+// together, consecutive kills are joined in the kill ring and Alt+Y follows a yank only
+type editAction int
+
+const (
+	actionOther editAction = iota
+	actionInsert
+	actionKill
+	actionYank
+	actionUndo
+)
+
+// editState is a copy of the command and of the cursor, restored by undo and redo
+type editState struct {
+	display [][]rune
+	row     int
+	col     int
+}
+
+func (t *Terminal) snapshot() editState {
+	display := make([][]rune, len(t.display))
+	for i, row := range t.display {
+		display[i] = slices.Clone(row)
+	}
+	return editState{display: display, row: t.pos.row, col: t.pos.col}
+}
+
+func (t *Terminal) restore(state editState) {
+	t.clearCmd()
+	t.display = state.display
+	t.pos.row = state.row
+	t.pos.col = state.col
+	t.drawCmd()
+}
+
+func (s editState) equal(other editState) bool {
+	return slices.EqualFunc(s.display, other.display, slices.Equal)
+}
+
+// recordUndo saves the state before the key when the key changed the command
+func (t *Terminal) recordUndo(before editState) {
+	if t.action == actionUndo || before.equal(t.snapshot()) {
+		return
+	}
+	if t.action != actionInsert || t.lastAction != actionInsert {
+		t.undoStack = append(t.undoStack, before)
+	}
+	t.redoStack = nil
+}
+
+// undo restores the command before the last change, Ctrl+_
+func (t *Terminal) undo() {
+	t.action = actionUndo
+	if len(t.undoStack) == 0 {
+		return
+	}
+	t.redoStack = append(t.redoStack, t.snapshot())
+	state := t.undoStack[len(t.undoStack)-1]
+	t.undoStack = t.undoStack[:len(t.undoStack)-1]
+	t.restore(state)
+}
+
+// redo applies again the last undone change, Alt+_
+func (t *Terminal) redo() {
+	t.action = actionUndo
+	if len(t.redoStack) == 0 {
+		return
+	}
+	t.undoStack = append(t.undoStack, t.snapshot())
+	state := t.redoStack[len(t.redoStack)-1]
+	t.redoStack = t.redoStack[:len(t.redoStack)-1]
+	t.restore(state)
+}
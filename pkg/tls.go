@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLSConfig describes the certificates used to connect to the database server.
+// Paths can start with ~, ServerName is needed when connecting through a tunnel.
+type TLSConfig struct {
+	CA         string `yaml:"ca,omitempty"`
+	Cert       string `yaml:"cert,omitempty"`
+	Key        string `yaml:"key,omitempty"`
+	ServerName string `yaml:"server_name,omitempty"`
+	SkipVerify bool   `yaml:"skip_verify,omitempty"`
+}
+
+// Config loads the certificates into a crypto/tls configuration
+func (t TLSConfig) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.SkipVerify,
+	}
+
+	if t.CA != "" {
+		pem, err := os.ReadFile(expandHome(t.CA))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read tls ca: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", t.CA)
+		}
+	}
+
+	if t.Cert != "" || t.Key != "" {
+		if t.Cert == "" || t.Key == "" {
+			return nil, fmt.Errorf("tls cert and key must be set together")
+		}
+		pair, err := tls.LoadX509KeyPair(expandHome(t.Cert), expandHome(t.Key))
+		if err != nil {
+			return nil, fmt.Errorf("unable to load tls client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{pair}
+	}
+	return cfg, nil
+}
+
+// registerMySQLTLS registers the configuration with the mysql driver, returning the name to use as tls= parameter.
+// The name is derived from the configuration, so databases sharing the same certificates share the registration.
+func registerMySQLTLS(t TLSConfig) (string, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(fmt.Appendf(nil, "%q %q %q %q %t", t.CA, t.Cert, t.Key, t.ServerName, t.SkipVerify))
+	name := "connect-" + hex.EncodeToString(sum[:8])
+	return name, mysql.RegisterTLSConfig(name, cfg)
+}
+
+// postgresTLSParams translates the configuration to the libpq ssl parameters
+func postgresTLSParams(t TLSConfig) map[string]string {
+	params := map[string]string{"sslmode": "verify-full"}
+	if t.SkipVerify {
+		params["sslmode"] = "require"
+	}
+	if t.CA != "" {
+		params["sslrootcert"] = expandHome(t.CA)
+	}
+	if t.Cert != "" {
+		params["sslcert"] = expandHome(t.Cert)
+	}
+	if t.Key != "" {
+		params["sslkey"] = expandHome(t.Key)
+	}
+	return params
+}
@@ -0,0 +1,72 @@
+package pkg_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+// writeCertificate generates a self signed certificate and its key, returning their paths
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db.internal"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	writeFile(t, certPath, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+	writeFile(t, keyPath, string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})))
+	return certPath, keyPath
+}
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := writeCertificate(t, dir)
+
+	cfg, err := pkg.TLSConfig{CA: cert, Cert: cert, Key: key, ServerName: "db.internal"}.Config()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if cfg.RootCAs == nil || len(cfg.Certificates) != 1 || cfg.ServerName != "db.internal" {
+		t.Errorf("expect ca, client certificate and server name to be loaded, got %+v", cfg)
+	}
+
+	table := []pkg.TLSConfig{
+		{CA: filepath.Join(dir, "missing.pem")},
+		{CA: key},
+		{Cert: cert},
+	}
+	for _, tt := range table {
+		if _, err := tt.Config(); err == nil {
+			t.Errorf("%+v: expected error", tt)
+		}
+	}
+}
@@ -24,6 +24,19 @@ func addrFromString(addr string) Address {
 	return Address{Net: "tcp", Addr: addr}
 }
 
+// ParseTunnel splits a user@host[:port] tunnel specification, the ssh port defaults to 22
+func ParseTunnel(tunnel string) (user string, sshAddr string, err error) {
+	user, sshAddr, ok := strings.Cut(tunnel, "@")
+	if !ok || user == "" || sshAddr == "" {
+		return "", "", fmt.Errorf("invalid tunnel configuration: %s", tunnel)
+	}
+
+	if _, _, err := net.SplitHostPort(sshAddr); err != nil {
+		sshAddr = net.JoinHostPort(sshAddr, "22")
+	}
+	return user, sshAddr, nil
+}
+
 type TunnelInfo struct {
 	User string
 
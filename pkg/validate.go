@@ -0,0 +1,406 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"codeberg.org/ale-cci/connect/pkg/terminal"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// SupportedDrivers lists the database/sql drivers compiled in the connect binaries
+var SupportedDrivers = []string{DriverMySQL, DriverPostgres, DriverSQLite}
+
+// ValidationError is a problem found in a configuration file, located at the offending YAML node
+type ValidationError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+type validator struct {
+	file    string
+	errors  []ValidationError
+	visited map[string]bool
+
+	// credential references are checked once every included file has been read
+	credentials map[string]bool
+	references  []credentialReference
+}
+
+type credentialReference struct {
+	name string
+	err  ValidationError
+}
+
+func (v *validator) report(node *yamlv3.Node, format string, args ...any) {
+	v.errors = append(v.errors, v.at(node, format, args...))
+}
+
+func (v *validator) at(node *yamlv3.Node, format string, args ...any) ValidationError {
+	return ValidationError{
+		File:    v.file,
+		Line:    node.Line,
+		Column:  node.Column,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// mapping checks that node is a mapping without duplicated or unknown keys, calling field for each known key
+func (v *validator) mapping(node *yamlv3.Node, where string, known []string, field func(key string, keyNode, value *yamlv3.Node)) {
+	if node.Kind != yamlv3.MappingNode {
+		if !isNull(node) {
+			v.report(node, "%s must be a mapping", where)
+		}
+		return
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, value := node.Content[i], node.Content[i+1]
+		key := keyNode.Value
+
+		if seen[key] {
+			v.report(keyNode, "duplicated key %q in %s", key, where)
+			continue
+		}
+		seen[key] = true
+
+		if known != nil && !contains(known, key) {
+			v.report(keyNode, "unknown key %q in %s", key, where)
+			continue
+		}
+		field(key, keyNode, value)
+	}
+}
+
+func (v *validator) str(node *yamlv3.Node, where string) (string, bool) {
+	if node.Kind != yamlv3.ScalarNode {
+		v.report(node, "%s must be a string", where)
+		return "", false
+	}
+	return node.Value, true
+}
+
+func (v *validator) integer(node *yamlv3.Node, where string, minValue, maxValue int) (int, bool) {
+	value, err := strconv.Atoi(node.Value)
+	if node.Kind != yamlv3.ScalarNode || err != nil {
+		v.report(node, "%s must be an integer", where)
+		return 0, false
+	}
+	if value < minValue || value > maxValue {
+		v.report(node, "%s must be between %d and %d, got %d", where, minValue, maxValue, value)
+		return value, false
+	}
+	return value, true
+}
+
+func (v *validator) strings(node *yamlv3.Node, where string, each func(item *yamlv3.Node, value string)) {
+	if node.Kind != yamlv3.SequenceNode {
+		if !isNull(node) {
+			v.report(node, "%s must be a list of strings", where)
+		}
+		return
+	}
+	for _, item := range node.Content {
+		if value, ok := v.str(item, where+" item"); ok && each != nil {
+			each(item, value)
+		}
+	}
+}
+
+func isNull(node *yamlv3.Node) bool {
+	return node.Kind == yamlv3.ScalarNode && node.Tag == "!!null"
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *validator) credential(alias string, node *yamlv3.Node) {
+	where := fmt.Sprintf("credentials.%s", alias)
+	known := []string{"username", "password", "password_cmd", "password_env", "password_file", "keyring"}
+
+	v.mapping(node, where, known, func(key string, keyNode, value *yamlv3.Node) {
+		if key == "keyring" {
+			v.mapping(value, where+".keyring", nil, func(attr string, _, attrValue *yamlv3.Node) {
+				v.str(attrValue, where+".keyring."+attr)
+			})
+			return
+		}
+		v.str(value, where+"."+key)
+	})
+}
+
+func (v *validator) database(alias string, node *yamlv3.Node) {
+	where := fmt.Sprintf("databases.%s", alias)
+	known := []string{"url", "host", "port", "alias", "database", "tunnel", "driver", "tag", "policy", "params", "tls"}
+
+	hasURL := false
+	hasHost := false
+	hasDriver := false
+	sqlite := false
+	v.mapping(node, where, known, func(key string, keyNode, value *yamlv3.Node) {
+		field := where + "." + key
+		switch key {
+		case "port":
+			v.integer(value, field, 0, 65535)
+
+		case "alias":
+			if name, ok := v.str(value, field); ok {
+				v.references = append(v.references, credentialReference{
+					name: name,
+					err:  v.at(value, "%s references unknown credential %q", field, name),
+				})
+			}
+
+		case "url":
+			if raw, ok := v.str(value, field); ok {
+				hasURL = true
+				info, _, err := ParseURL(raw)
+				if err != nil {
+					v.report(value, "%s: %v", field, err)
+				}
+				sqlite = info.Driver == DriverSQLite
+			}
+
+		case "driver":
+			hasDriver = true
+			if name, ok := v.str(value, field); ok && !contains(SupportedDrivers, name) {
+				v.report(value, "%s: unsupported driver %q (supported: %v)", field, name, SupportedDrivers)
+			}
+			sqlite = value.Value == DriverSQLite
+
+		case "tunnel":
+			if tunnel, ok := v.str(value, field); ok && tunnel != "" {
+				if _, _, err := ParseTunnel(tunnel); err != nil {
+					v.report(value, "%s: %v", field, err)
+				}
+			}
+
+		case "tag":
+			v.strings(value, field, nil)
+
+		case "policy":
+			v.policy(value, field)
+
+		case "params":
+			v.mapping(value, field, nil, func(param string, _, paramValue *yamlv3.Node) {
+				v.str(paramValue, field+"."+param)
+			})
+
+		case "tls":
+			v.tls(value, field)
+
+		default:
+			if s, ok := v.str(value, field); ok && key == "host" {
+				hasHost = s != ""
+			}
+		}
+	})
+
+	if node.Kind == yamlv3.MappingNode && !hasURL {
+		if !hasHost && !sqlite {
+			v.report(node, "%s: missing host", where)
+		}
+		if !hasDriver {
+			v.report(node, "%s: missing driver", where)
+		}
+	}
+}
+
+func (v *validator) tls(node *yamlv3.Node, where string) {
+	files := map[string]bool{}
+	v.mapping(node, where, []string{"ca", "cert", "key", "server_name", "skip_verify"}, func(key string, keyNode, value *yamlv3.Node) {
+		if key == "skip_verify" {
+			if value.Kind != yamlv3.ScalarNode || value.Tag != "!!bool" {
+				v.report(value, "%s.%s must be true or false", where, key)
+			}
+			return
+		}
+		if s, ok := v.str(value, where+"."+key); ok && s != "" {
+			files[key] = true
+		}
+	})
+
+	if files["cert"] != files["key"] {
+		v.report(node, "%s: cert and key must be set together", where)
+	}
+}
+
+func (v *validator) policy(node *yamlv3.Node, where string) {
+	v.mapping(node, where, []string{"allow_tables", "deny_tables", "mask"}, func(key string, keyNode, value *yamlv3.Node) {
+		field := where + "." + key
+		switch key {
+		case "mask":
+			if value.Kind != yamlv3.SequenceNode {
+				v.report(value, "%s must be a list", field)
+				return
+			}
+			for _, rule := range value.Content {
+				v.mapping(rule, field+" item", []string{"column", "strategy"}, func(ruleKey string, _, ruleValue *yamlv3.Node) {
+					s, ok := v.str(ruleValue, field+"."+ruleKey)
+					if ok && ruleKey == "strategy" && s != MaskHash && s != MaskRedact {
+						v.report(ruleValue, "%s.strategy must be %s or %s, got %q", field, MaskHash, MaskRedact, s)
+					}
+				})
+			}
+
+		default:
+			v.strings(value, field, func(item *yamlv3.Node, pattern string) {
+				if _, err := path.Match(pattern, ""); err != nil {
+					v.report(item, "%s: invalid glob %q", field, pattern)
+				}
+			})
+		}
+	})
+}
+
+func (v *validator) options(node *yamlv3.Node) {
+	v.mapping(node, "options", []string{"autolimit", "histsize", "tabsize", "theme", "colors"}, func(key string, keyNode, value *yamlv3.Node) {
+		switch key {
+		case "theme":
+			if theme, ok := v.str(value, "options.theme"); ok {
+				if _, known := terminal.Themes[theme]; !known {
+					v.report(value, "unknown theme %q, expected one of %s", theme, strings.Join(sortedKeys(terminal.Themes), ", "))
+				}
+			}
+		case "colors":
+			v.mapping(value, "options.colors", sortedKeys(terminal.TokenKinds), func(kind string, _, color *yamlv3.Node) {
+				if sgr, ok := v.str(color, "options.colors."+kind); ok && !sgrParameters.MatchString(sgr) {
+					v.report(color, "options.colors.%s must be SGR parameters like 1;34, got %q", kind, sgr)
+				}
+			})
+		default:
+			v.integer(value, "options."+key, 0, 1<<31-1)
+		}
+	})
+}
+
+var sgrParameters = regexp.MustCompile(`^[0-9;]*$`)
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateConfigFile checks a configuration file, and the files it includes, reporting every problem
+// with its position. The returned error is set only when a file cannot be read or parsed at all.
+func ValidateConfigFile(filename string) ([]ValidationError, error) {
+	v := validator{visited: map[string]bool{}, credentials: map[string]bool{}}
+	if err := v.validateFile(filename); err != nil {
+		return nil, err
+	}
+
+	// aliases missing from the configuration can still live in the encrypted credentials file
+	_, err := os.Stat(filepath.Join(filepath.Dir(filename), EncryptedCredentialsFile))
+	if err != nil {
+		for _, ref := range v.references {
+			if !v.credentials[ref.name] {
+				v.errors = append(v.errors, ref.err)
+			}
+		}
+	}
+
+	sort.SliceStable(v.errors, func(i, j int) bool {
+		a, b := v.errors[i], v.errors[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return v.errors, nil
+}
+
+func (v *validator) validateFile(filename string) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if v.visited[abs] {
+		return fmt.Errorf("include cycle detected on %s", filename)
+	}
+	v.visited[abs] = true
+	defer delete(v.visited, abs)
+
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	parent := v.file
+	v.file = filename
+	defer func() { v.file = parent }()
+
+	includes := []string{}
+	known := []string{"include", "credentials", "databases", "options", "policy"}
+	v.mapping(document.Content[0], "configuration", known, func(key string, keyNode, value *yamlv3.Node) {
+		switch key {
+		case "include":
+			v.strings(value, "include", func(item *yamlv3.Node, pattern string) {
+				if !filepath.IsAbs(pattern) {
+					pattern = filepath.Join(filepath.Dir(filename), pattern)
+				}
+				matches, err := filepath.Glob(pattern)
+				if err != nil {
+					v.report(item, "include: invalid glob %q", item.Value)
+					return
+				}
+				sort.Strings(matches)
+				includes = append(includes, matches...)
+			})
+
+		case "credentials":
+			v.mapping(value, "credentials", nil, func(alias string, _, credential *yamlv3.Node) {
+				v.credentials[alias] = true
+				v.credential(alias, credential)
+			})
+
+		case "databases":
+			v.mapping(value, "databases", nil, func(alias string, _, database *yamlv3.Node) {
+				v.database(alias, database)
+			})
+
+		case "options":
+			v.options(value)
+
+		case "policy":
+			v.policy(value, "policy")
+		}
+	})
+
+	for _, included := range includes {
+		if err := v.validateFile(included); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package pkg_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestValidateConfigFile(t *testing.T) {
+	table := []struct {
+		name   string
+		config string
+		expect []string
+	}{
+		{
+			name: "valid configuration",
+			config: `credentials:
+  dev:
+    username: root
+    password_env: DEV_PASSWORD
+databases:
+  sales:
+    host: db.internal
+    port: 3306
+    alias: dev
+    driver: mysql
+    tunnel: web@bastion:2222
+    tag: [prod]
+    policy:
+      deny_tables: [secrets_*]
+      mask:
+        - column: email
+          strategy: hash
+options:
+  autolimit: 100
+`,
+			expect: []string{},
+		},
+		{
+			name: "unknown keys",
+			config: `databases:
+  sales:
+    host: db.internal
+    driver: mysql
+    hots: typo
+optoins:
+  autolimit: 1
+`,
+			expect: []string{
+				`config.yaml:5:5: unknown key "hots" in databases.sales`,
+				`config.yaml:6:1: unknown key "optoins" in configuration`,
+			},
+		},
+		{
+			name: "invalid database fields",
+			config: `credentials:
+  dev:
+    username: root
+databases:
+  sales:
+    host: db.internal
+    port: 70000
+    alias: prod
+    driver: oracle
+    tunnel: bastion
+  billing:
+    port: abc
+`,
+			expect: []string{
+				`config.yaml:7:11: databases.sales.port must be between 0 and 65535, got 70000`,
+				`config.yaml:8:12: databases.sales.alias references unknown credential "prod"`,
+				`config.yaml:9:13: databases.sales.driver: unsupported driver "oracle" (supported: [mysql postgres sqlite])`,
+				`config.yaml:10:13: databases.sales.tunnel: invalid tunnel configuration: bastion`,
+				`config.yaml:12:5: databases.billing: missing host`,
+				`config.yaml:12:5: databases.billing: missing driver`,
+				`config.yaml:12:11: databases.billing.port must be an integer`,
+			},
+		},
+		{
+			name: "invalid policy",
+			config: `policy:
+  allow_tables: ["[a-"]
+  mask:
+    - column: email
+      strategy: rot13
+`,
+			expect: []string{
+				`config.yaml:2:18: policy.allow_tables: invalid glob "[a-"`,
+				`config.yaml:5:17: policy.mask.strategy must be hash or redact, got "rot13"`,
+			},
+		},
+		{
+			name: "duplicated keys",
+			config: `credentials:
+  dev:
+    username: root
+  dev:
+    username: admin
+`,
+			expect: []string{
+				`config.yaml:4:3: duplicated key "dev" in credentials`,
+			},
+		},
+		{
+			name: "invalid theme",
+			config: `options:
+  theme: solarized
+  colors:
+    keyword: "1;34"
+    string: green
+    operator: "31"
+`,
+			expect: []string{
+				`config.yaml:2:10: unknown theme "solarized", expected one of default, light, mono, none`,
+				`config.yaml:5:13: options.colors.string must be SGR parameters like 1;34, got "green"`,
+				`config.yaml:6:5: unknown key "operator" in options.colors`,
+			},
+		},
+	}
+
+	for _, tc := range table {
+		dir := t.TempDir()
+		t.Chdir(dir)
+		writeFile(t, filepath.Join(dir, "config.yaml"), tc.config)
+
+		got, err := pkg.ValidateConfigFile("config.yaml")
+		if err != nil {
+			t.Fatalf("%s: expected nil error, got %v", tc.name, err)
+		}
+
+		if len(got) != len(tc.expect) {
+			t.Errorf("%s: expect %d errors, got %v", tc.name, len(tc.expect), got)
+			continue
+		}
+		for i := range got {
+			if got[i].Error() != tc.expect[i] {
+				t.Errorf("%s: expect %q, got %q", tc.name, tc.expect[i], got[i].Error())
+			}
+		}
+	}
+}
+
+func TestValidateConfigFileIncludes(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	writeFile(t, "config.yaml", `include:
+  - conf.d/*.yaml
+databases:
+  sales:
+    host: db.internal
+    alias: shared
+    driver: mysql
+`)
+	writeFile(t, filepath.Join("conf.d", "credentials.yaml"), `credentials:
+  shared:
+    username: root
+    passwrd: typo
+`)
+
+	got, err := pkg.ValidateConfigFile("config.yaml")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	expect := `conf.d/credentials.yaml:4:5: unknown key "passwrd" in credentials.shared`
+	if len(got) != 1 || got[0].Error() != expect {
+		t.Errorf("expect [%s], got %v", expect, got)
+	}
+}
+
+func TestParseTunnel(t *testing.T) {
+	table := []struct {
+		tunnel string
+		user   string
+		addr   string
+		fails  bool
+	}{
+		{tunnel: "web@bastion", user: "web", addr: "bastion:22"},
+		{tunnel: "web@bastion:2222", user: "web", addr: "bastion:2222"},
+		{tunnel: "bastion", fails: true},
+		{tunnel: "web@", fails: true},
+	}
+
+	for _, tc := range table {
+		user, addr, err := pkg.ParseTunnel(tc.tunnel)
+		if tc.fails {
+			if err == nil {
+				t.Errorf("ParseTunnel(%q): expected error", tc.tunnel)
+			}
+			continue
+		}
+		if err != nil || user != tc.user || addr != tc.addr {
+			t.Errorf("ParseTunnel(%q) = %q, %q, %v, expected %q, %q", tc.tunnel, user, addr, err, tc.user, tc.addr)
+		}
+	}
+}
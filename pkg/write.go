@@ -0,0 +1,264 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// WriteConfigFile saves the configuration editing the YAML tree of the existing file, so that comments,
+// key order and unknown keys survive. The file is replaced atomically, keeping the previous version as .bak.
+func WriteConfigFile(filename string, cnf Config) error {
+	content, err := os.ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var document yamlv3.Node
+	if err := yamlv3.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if len(document.Content) == 0 {
+		document = yamlv3.Node{
+			Kind:    yamlv3.DocumentNode,
+			Content: []*yamlv3.Node{{Kind: yamlv3.MappingNode, Tag: "!!map"}},
+		}
+	}
+
+	var updated yamlv3.Node
+	if err := updated.Encode(cnf); err != nil {
+		return err
+	}
+	updateNode(document.Content[0], &updated, reflect.TypeOf(cnf))
+
+	var buf bytes.Buffer
+	encoder := yamlv3.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	return WriteFileAtomic(filename, buf.Bytes(), 0600)
+}
+
+// WriteFileAtomic replaces the file through a temporary file and a rename, so that a crash never leaves it
+// truncated. The previous content is kept in filename.bak, the permissions of an existing file are preserved.
+func WriteFileAtomic(filename string, data []byte, perm fs.FileMode) error {
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	previous, err := os.ReadFile(filename)
+	if err == nil {
+		if info, err := os.Stat(filename); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := os.WriteFile(filename+".bak", previous, perm); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// updateNode rewrites old in place to match the freshly encoded node, guided by the Go type the nodes
+// were encoded from: keys of Go maps missing from updated are deleted, keys unknown to a struct are kept.
+func updateNode(old, updated *yamlv3.Node, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if old.Kind != updated.Kind || t == nil {
+		replaceNode(old, updated)
+		return
+	}
+
+	switch old.Kind {
+	case yamlv3.ScalarNode:
+		if old.Value != updated.Value || old.ShortTag() != updated.ShortTag() {
+			old.Value = updated.Value
+			old.Tag = updated.Tag
+			old.Style = updated.Style
+		}
+
+	case yamlv3.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			replaceNode(old, updated)
+			return
+		}
+		for i, item := range updated.Content {
+			if i < len(old.Content) {
+				updateNode(old.Content[i], item, t.Elem())
+			} else {
+				old.Content = append(old.Content, pruneNode(item, t.Elem()))
+			}
+		}
+		old.Content = old.Content[:len(updated.Content)]
+
+	case yamlv3.MappingNode:
+		updateMapping(old, updated, t)
+
+	default:
+		replaceNode(old, updated)
+	}
+}
+
+func updateMapping(old, updated *yamlv3.Node, t reflect.Type) {
+	fields := yamlFields(t)
+	isMap := t.Kind() == reflect.Map
+
+	values := map[string]*yamlv3.Node{}
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		values[updated.Content[i].Value] = updated.Content[i+1]
+	}
+
+	// update or drop the existing keys, keeping their position
+	content := []*yamlv3.Node{}
+	present := map[string]bool{}
+	for i := 0; i+1 < len(old.Content); i += 2 {
+		key, value := old.Content[i], old.Content[i+1]
+		fieldType, known := fields[key.Value]
+		if isMap {
+			fieldType, known = t.Elem(), true
+		}
+
+		newValue, ok := values[key.Value]
+		switch {
+		case ok:
+			updateNode(value, newValue, fieldType)
+		case known:
+			// deleted entry or empty omitempty field
+			continue
+		}
+		present[key.Value] = true
+		content = append(content, key, value)
+	}
+
+	// new keys are appended in the order of the encoded node
+	for i := 0; i+1 < len(updated.Content); i += 2 {
+		key, value := updated.Content[i], updated.Content[i+1]
+		if present[key.Value] {
+			continue
+		}
+
+		fieldType := fields[key.Value]
+		if isMap {
+			fieldType = t.Elem()
+		}
+		value = pruneNode(value, fieldType)
+		if !isMap && isZeroNode(value) {
+			continue
+		}
+		content = append(content, key, value)
+	}
+	old.Content = content
+}
+
+// replaceNode overwrites old with updated, keeping the comments attached to old
+func replaceNode(old, updated *yamlv3.Node) {
+	head, line, foot := old.HeadComment, old.LineComment, old.FootComment
+	*old = *updated
+	old.HeadComment, old.LineComment, old.FootComment = head, line, foot
+}
+
+// pruneNode removes the zero valued fields of newly written structs, e.g. tunnel: "" of a connection without tunnel
+func pruneNode(node *yamlv3.Node, t reflect.Type) *yamlv3.Node {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || node.Kind != yamlv3.MappingNode {
+		return node
+	}
+
+	fields := yamlFields(t)
+	content := []*yamlv3.Node{}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if t.Kind() == reflect.Map {
+			content = append(content, key, pruneNode(value, t.Elem()))
+			continue
+		}
+
+		value = pruneNode(value, fields[key.Value])
+		if !isZeroNode(value) {
+			content = append(content, key, value)
+		}
+	}
+	node.Content = content
+	return node
+}
+
+func isZeroNode(node *yamlv3.Node) bool {
+	switch node.Kind {
+	case yamlv3.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return true
+		case "!!str":
+			return node.Value == ""
+		case "!!int", "!!float":
+			return node.Value == "0"
+		case "!!bool":
+			return node.Value == "false"
+		}
+	case yamlv3.MappingNode, yamlv3.SequenceNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
+
+// yamlFields maps the yaml keys of a struct to the types of its fields
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
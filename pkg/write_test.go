@@ -0,0 +1,110 @@
+package pkg_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"codeberg.org/ale-cci/connect/pkg"
+)
+
+func TestWriteConfigFilePreservesLayout(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	original := `# shared credentials
+credentials:
+  prod:
+    username: admin # the admin
+databases:
+  # sales database
+  sales:
+    host: 10.0.1.5
+    port: 3306
+    alias: prod
+    driver: mysql
+    owner: data-team
+  local:
+    host: /var/run/mysqld.sock
+    port: 0 # unix socket
+    driver: mysql
+options:
+  autolimit: 100
+`
+	writeFile(t, configPath, original)
+
+	cnf, err := pkg.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := cnf.Databases["sales"]
+	info.Port = 3307
+	info.Tag = []string{"production"}
+	cnf.Databases["sales"] = info
+	delete(cnf.Databases, "local")
+	cnf.Databases["billing"] = pkg.ConnectionInfo{Host: "db.internal", Driver: "mysql"}
+
+	if err := pkg.WriteConfigFile(configPath, cnf); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	got, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `# shared credentials
+credentials:
+  prod:
+    username: admin # the admin
+databases:
+  # sales database
+  sales:
+    host: 10.0.1.5
+    port: 3307
+    alias: prod
+    driver: mysql
+    owner: data-team
+    tag:
+      - production
+  billing:
+    host: db.internal
+    driver: mysql
+options:
+  autolimit: 100
+`
+	if string(got) != expect {
+		t.Errorf("expect:\n%s\ngot:\n%s", expect, got)
+	}
+
+	backup, err := os.ReadFile(configPath + ".bak")
+	if err != nil || string(backup) != original {
+		t.Errorf("expect the previous content in the backup, got %q, %v", backup, err)
+	}
+}
+
+func TestWriteConfigFileNew(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "connect", "config.yaml")
+
+	cnf := pkg.Config{
+		Databases: map[string]pkg.ConnectionInfo{
+			"local": {Driver: "sqlite", Database: "local.db"},
+		},
+	}
+	if err := pkg.WriteConfigFile(configPath, cnf); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	got, err := pkg.ReadConfigFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Databases["local"].Database != "local.db" {
+		t.Errorf("expect the written configuration, got %+v", got)
+	}
+
+	info, err := os.Stat(configPath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expect a 0600 file, got %v, %v", info, err)
+	}
+	if _, err := os.Stat(configPath + ".bak"); err == nil {
+		t.Errorf("expect no backup for a new file")
+	}
+}
@@ -6,10 +6,18 @@ _script()
   cur="${COMP_WORDS[COMP_CWORD]}"
   prev="${COMP_WORDS[COMP_CWORD-1]}"
 
-  if [[ ${COMP_CWORD} -eq 1 ]]; then
-    opts=$(connect --completions)
-    COMPREPLY=( $(compgen -W "${opts}" -- "${cur}") )
+  if [[ ${prev} == "--tag" || ${prev} == "-tag" ]]; then
+    opts=$(${COMP_WORDS[0]} --completions tags)
+  elif [[ ${cur} == -* ]]; then
+    if [[ ${COMP_WORDS[0]} == "connect-mcp" ]]; then
+      opts="-http -mode -tag"
+    else
+      opts="--tag --healthcheck --version"
+    fi
+  else
+    opts=$(${COMP_WORDS[0]} --completions)
   fi
+  COMPREPLY=( $(compgen -W "${opts}" -- "${cur}") )
   return 0
 }
-complete -F _script connect
+complete -F _script connect connect-mcp